@@ -11,4 +11,10 @@ type MongoCollection struct {
 	Problem           string `yaml:"problem"`
 	Account           string `yaml:"account"`
 	SubmissionSnippet string `yaml:"submission_snippet"`
+	SubmissionJob     string `yaml:"submission_job"`
+	IOTestCase        string `yaml:"io_test_case"`
+	ProblemFixture    string `yaml:"problem_fixture"`
+	JudgeInstance     string `yaml:"judge_instance"`
+	// JudgeLog names the GridFS bucket archived judge logs are kept in.
+	JudgeLog string `yaml:"judge_log"`
 }
@@ -1,5 +1,14 @@
 package configs
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
 type Logic struct {
 	Judge Judge `yaml:"judge"`
 }
@@ -10,19 +19,324 @@ type TestCaseRun struct {
 	CPUQuota        int64    `yaml:"cpu_quota"`
 	CodeFileName    string   `yaml:"code_file_name"`
 	TestFileName    string   `yaml:"test_file_name"`
+	ProgramFileName string   `yaml:"program_file_name"`
 	StdOut          bool     `yaml:"stdOut"`
 	StdErr          bool     `yaml:"stdErr"`
 	DownloadTestUrl *string  `yaml:"download_test_url,omitempty"`
 	TestLibraryName *string  `yaml:"test_library_name,omitempty"`
+	ResultFormat    string   `yaml:"result_format"`
+	ReportsDir      string   `yaml:"reports_dir"`
+	NetworkMode     string   `yaml:"network_mode"`
+	Sandbox         Sandbox  `yaml:"sandbox"`
+	// TimeLimitGrace is how far measured CPU and wall time may exceed the
+	// problem's limit, to absorb runtime start-up or in-container harness builds.
+	TimeLimitGrace string `yaml:"time_limit_grace"`
+	// PoolSize is how many idle containers are kept started for this run
+	// configuration. Zero creates a container per run.
+	PoolSize    int      `yaml:"pool_size"`
+	IdleCommand []string `yaml:"idle_command"`
+	// Backend picks the sandbox implementation: "docker" (the default) or "native".
+	Backend string        `yaml:"backend"`
+	Native  NativeSandbox `yaml:"native"`
+	// Compile builds the submission together with the setter's test file
+	// before a harness run, for languages whose tests are compiled in. It
+	// replaces the language's own compile step for test harness problems.
+	Compile *Compile `yaml:"compile,omitempty"`
 }
 
+const (
+	ResultFormatJUnitXML = "junit_xml"
+	ResultFormatUnittest = "unittest"
+	ResultFormatGoTest   = "go_test"
+	ResultFormatRustTest = "rust_test"
+)
+
 type Language struct {
-	Value       string      `yaml:"value"`
-	Name        string      `yaml:"name"`
-	TestCaseRun TestCaseRun `yaml:"test_case_run"`
+	Value         string       `yaml:"value"`
+	Name          string       `yaml:"name"`
+	Compile       *Compile     `yaml:"compile,omitempty"`
+	TestCaseRun   TestCaseRun  `yaml:"test_case_run"`
+	StandardIORun *TestCaseRun `yaml:"standard_io_run,omitempty"`
+	// MaxConcurrency caps how many submissions of this language one judge
+	// process runs at once. Zero means only the worker count limits it.
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// Checker is how setter checkers written in this language are built and
+	// run. Nil means the language can't be used for checkers.
+	Checker *Checker `yaml:"checker,omitempty"`
+	// Interactor is configured like Checker, but its stdin and stdout are
+	// connected to the contestant's program.
+	Interactor *Checker `yaml:"interactor,omitempty"`
+}
+
+// Checker configures testlib-compatible checker programs. The run command
+// gets the test's input, the contestant's output and the jury answer as
+// $INPUT_FILE, $OUTPUT_FILE and $ANSWER_FILE.
+type Checker struct {
+	Compile   *Compile    `yaml:"compile,omitempty"`
+	Run       TestCaseRun `yaml:"run"`
+	TimeLimit string      `yaml:"time_limit"`
+	Memory    string      `yaml:"memory"`
+}
+
+// GetTimeLimitDuration returns how long a checker may run per test, 10s by default.
+func (c Checker) GetTimeLimitDuration() (time.Duration, error) {
+	if c.TimeLimit == "" {
+		return 10 * time.Second, nil
+	}
+	return time.ParseDuration(c.TimeLimit)
 }
+
+// GetMemoryInByte returns the checker's memory limit, 256MiB by default.
+func (c Checker) GetMemoryInByte() (uint64, error) {
+	if c.Memory == "" {
+		return 256 << 20, nil
+	}
+	return humanize.ParseBytes(c.Memory)
+}
+
 type Judge struct {
 	Schedule             string     `yaml:"schedule"`
 	Languages            []Language `yaml:"languages"`
 	SubmissionRetryDelay string     `yaml:"submission_retry_delay"`
+	VisibilityTimeout    string     `yaml:"visibility_timeout"`
+	MaxAttempts          int        `yaml:"max_attempts"`
+	DependencyCacheDir   string     `yaml:"dependency_cache_dir"`
+	Workers              int        `yaml:"workers"`
+	// PinnedCPUs lists the cores run containers are pinned to, one run per
+	// core, in cpuset notation such as "2-5,7". Empty disables pinning.
+	PinnedCPUs string  `yaml:"pinned_cpus"`
+	RunCode    RunCode `yaml:"run_code"`
+	Janitor    Janitor `yaml:"janitor"`
+	Files      Files   `yaml:"files"`
+	Output     Output  `yaml:"output"`
+}
+
+// Output limits how much of a program's output the judge keeps.
+type Output struct {
+	// RunLimit caps stdout and stderr of every compile and run. A program
+	// that writes more is stopped with an output limit exceeded verdict.
+	RunLimit string `yaml:"run_limit"`
+	// GradingResultLimit caps the log stored on the submission itself; the
+	// whole log is archived separately.
+	GradingResultLimit string `yaml:"grading_result_limit"`
+	// ArchiveLimit caps the archived log of one submission.
+	ArchiveLimit string `yaml:"archive_limit"`
+}
+
+// GetRunLimitInByte returns the output cap of a run, 8MiB by default.
+func (o Output) GetRunLimitInByte() (uint64, error) {
+	if o.RunLimit == "" {
+		return 8 << 20, nil
+	}
+	return humanize.ParseBytes(o.RunLimit)
+}
+
+// GetGradingResultLimitInByte returns the size of the stored log, 64KiB by default.
+func (o Output) GetGradingResultLimitInByte() (uint64, error) {
+	if o.GradingResultLimit == "" {
+		return 64 << 10, nil
+	}
+	return humanize.ParseBytes(o.GradingResultLimit)
+}
+
+// GetArchiveLimitInByte returns the size of an archived log, 64MiB by default.
+func (o Output) GetArchiveLimitInByte() (uint64, error) {
+	if o.ArchiveLimit == "" {
+		return 64 << 20, nil
+	}
+	return humanize.ParseBytes(o.ArchiveLimit)
+}
+
+// Files limits the fixture files problems carry and the source files a
+// submission is made of.
+type Files struct {
+	MaxFixtureSize         string `yaml:"max_fixture_size"`
+	MaxProblemFixturesSize string `yaml:"max_problem_fixtures_size"`
+	MaxSubmissionFiles     int    `yaml:"max_submission_files"`
+	// MaxSubmissionSize caps the main source and every other file together.
+	MaxSubmissionSize string `yaml:"max_submission_size"`
+}
+
+// GetMaxFixtureSizeInByte returns the largest fixture file accepted, 1MiB by default.
+func (f Files) GetMaxFixtureSizeInByte() (uint64, error) {
+	if f.MaxFixtureSize == "" {
+		return 1 << 20, nil
+	}
+	return humanize.ParseBytes(f.MaxFixtureSize)
+}
+
+// GetMaxProblemFixturesSizeInByte returns how large all fixtures of a problem
+// may be together, 8MiB by default.
+func (f Files) GetMaxProblemFixturesSizeInByte() (uint64, error) {
+	if f.MaxProblemFixturesSize == "" {
+		return 8 << 20, nil
+	}
+	return humanize.ParseBytes(f.MaxProblemFixturesSize)
+}
+
+// GetMaxSubmissionFiles returns how many files a submission may add to its
+// main source, 16 by default.
+func (f Files) GetMaxSubmissionFiles() int {
+	if f.MaxSubmissionFiles <= 0 {
+		return 16
+	}
+	return f.MaxSubmissionFiles
+}
+
+// GetMaxSubmissionSizeInByte returns how large a submission may be, 256KiB by default.
+func (f Files) GetMaxSubmissionSizeInByte() (uint64, error) {
+	if f.MaxSubmissionSize == "" {
+		return 256 << 10, nil
+	}
+	return humanize.ParseBytes(f.MaxSubmissionSize)
+}
+
+// Janitor configures the cleanup of state left behind by crashed judges.
+type Janitor struct {
+	Interval string `yaml:"interval"`
+	// StaleAfter is how old a workspace of a running judge has to be before
+	// it is taken for leaked. It must be longer than any compilation or run.
+	StaleAfter string `yaml:"stale_after"`
+}
+
+// GetIntervalDuration returns how often the janitor runs, every 5 minutes by default.
+func (j Janitor) GetIntervalDuration() (time.Duration, error) {
+	if j.Interval == "" {
+		return 5 * time.Minute, nil
+	}
+	return time.ParseDuration(j.Interval)
+}
+
+// GetStaleAfterDuration returns the age of a leaked workspace, 1 hour by default.
+func (j Janitor) GetStaleAfterDuration() (time.Duration, error) {
+	if j.StaleAfter == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(j.StaleAfter)
+}
+
+// RunCode configures running a program once against custom input, outside
+// of judging.
+type RunCode struct {
+	// RateLimit is how many runs each account may start per
+	// RateLimitWindow. Zero disables the limit.
+	RateLimit       int    `yaml:"rate_limit"`
+	RateLimitWindow string `yaml:"rate_limit_window"`
+	MaxInputSize    string `yaml:"max_input_size"`
+}
+
+func (r RunCode) GetRateLimitWindowDuration() (time.Duration, error) {
+	if r.RateLimitWindow == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(r.RateLimitWindow)
+}
+
+// GetMaxInputSizeInByte returns the largest custom input accepted, 1MiB by default.
+func (r RunCode) GetMaxInputSizeInByte() (uint64, error) {
+	if r.MaxInputSize == "" {
+		return 1 << 20, nil
+	}
+	return humanize.ParseBytes(r.MaxInputSize)
+}
+
+// GetLanguage returns the configured language with the given value, or nil.
+func (j Judge) GetLanguage(value string) *Language {
+	for i := range j.Languages {
+		if j.Languages[i].Value == value {
+			return &j.Languages[i]
+		}
+	}
+	return nil
+}
+
+// IsReservedFileName reports whether a file a language's compile or runs
+// write themselves has this name, so neither a fixture nor a submission file
+// may take it.
+func (j Judge) IsReservedFileName(name string) bool {
+	for _, language := range j.Languages {
+		var names []string
+		if language.Compile != nil {
+			names = append(names, language.Compile.SourceFileName, language.Compile.ProgramFileName)
+		}
+		for _, run := range []*TestCaseRun{&language.TestCaseRun, language.StandardIORun} {
+			if run == nil {
+				continue
+			}
+			names = append(names, run.CodeFileName, run.TestFileName, run.ProgramFileName, run.ReportsDir)
+			if run.Compile != nil {
+				names = append(names, run.Compile.SourceFileName, run.Compile.ProgramFileName)
+			}
+		}
+		for _, reserved := range names {
+			if reserved != "" && (name == reserved || strings.HasPrefix(name, reserved+"/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetSourceFileName returns the name a submission's source is saved under:
+// the compiler's source file, or the file the test run executes.
+func (l Language) GetSourceFileName() string {
+	if l.Compile != nil {
+		return l.Compile.SourceFileName
+	}
+	if l.TestCaseRun.CodeFileName == "" && l.StandardIORun != nil {
+		return l.StandardIORun.CodeFileName
+	}
+	return l.TestCaseRun.CodeFileName
+}
+
+// GetScheduleInterval returns how often the judge polls the submission queue.
+// Only the "@every <duration>" form is supported.
+func (j Judge) GetScheduleInterval() (time.Duration, error) {
+	interval, found := strings.CutPrefix(strings.TrimSpace(j.Schedule), "@every ")
+	if !found {
+		return 0, fmt.Errorf("unsupported judge schedule %q, expected \"@every <duration>\"", j.Schedule)
+	}
+	return time.ParseDuration(strings.TrimSpace(interval))
+}
+
+func (j Judge) GetSubmissionRetryDelayDuration() (time.Duration, error) {
+	return time.ParseDuration(j.SubmissionRetryDelay)
+}
+
+func (j Judge) GetVisibilityTimeoutDuration() (time.Duration, error) {
+	return time.ParseDuration(j.VisibilityTimeout)
+}
+
+// GetPinnedCPUs expands PinnedCPUs into individual core IDs.
+func (j Judge) GetPinnedCPUs() ([]string, error) {
+	var cpus []string
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(j.PinnedCPUs, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pinned cpus %q: %w", j.PinnedCPUs, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pinned cpus %q: %w", j.PinnedCPUs, err)
+			}
+		}
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid pinned cpus range %q", part)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, strconv.Itoa(cpu))
+			}
+		}
+	}
+	return cpus, nil
 }
@@ -0,0 +1,50 @@
+package configs
+
+const (
+	SandboxBackendDocker = "docker"
+	SandboxBackendNative = "native"
+)
+
+// Sandbox hardens the container a submission runs in. Every control is
+// opt-in, so a toolchain that needs a writable root or extra processes can
+// still be configured.
+type Sandbox struct {
+	ReadOnlyRootFS      bool   `yaml:"read_only_root_fs"`
+	TmpfsDir            string `yaml:"tmpfs_dir"`
+	TmpfsSize           string `yaml:"tmpfs_size"`
+	User                string `yaml:"user"`
+	PidsLimit           int64  `yaml:"pids_limit"`
+	DropAllCapabilities bool   `yaml:"drop_all_capabilities"`
+	NoNewPrivileges     bool   `yaml:"no_new_privileges"`
+	SeccompProfile      string `yaml:"seccomp_profile"`
+	OpenFilesLimit      int64  `yaml:"open_files_limit"`
+	FileSizeLimit       string `yaml:"file_size_limit"`
+}
+
+// GetTmpfsDir returns where the writable scratch area is mounted, /tmp by default.
+func (s Sandbox) GetTmpfsDir() string {
+	if s.TmpfsDir != "" {
+		return s.TmpfsDir
+	}
+	return "/tmp"
+}
+
+// NativeSandbox configures the daemonless backend, which isolates runs with
+// Linux namespaces, a cgroup v2 group and rlimits instead of Docker.
+type NativeSandbox struct {
+	// RootFS is a directory holding the language's root filesystem, for
+	// example an exported image. The judge must run as root to use it.
+	RootFS string `yaml:"root_fs"`
+	// CgroupRoot is a cgroup v2 directory delegated to the judge, under
+	// which one group per run is created.
+	CgroupRoot string `yaml:"cgroup_root"`
+}
+
+// GetCgroupRoot returns the delegated cgroup directory, with a default
+// under the unified hierarchy.
+func (n NativeSandbox) GetCgroupRoot() string {
+	if n.CgroupRoot != "" {
+		return n.CgroupRoot
+	}
+	return "/sys/fs/cgroup/coodbox-judge"
+}
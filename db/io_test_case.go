@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// IOTestCase is one input/answer pair of a problem judged by comparing standard output.
+// Unlike TestCase it isn't tied to a language, and pairs are judged in Order.
+type IOTestCase struct {
+	UUID          string `json:"UUID" bson:"UUID"`
+	OfProblemUUID string `json:"ofProblemUUID" bson:"ofProblemUUID"`
+	Order         int    `json:"order" bson:"order"`
+	Input         string `json:"input" bson:"input"`
+	Answer        string `json:"answer" bson:"answer"`
+	CreatedAt     string `json:"createdAt" bson:"createdAt"`
+	// IsHidden keeps the test's details from contestants, who only see its verdict.
+	IsHidden bool `json:"isHidden" bson:"isHidden"`
+	// Subtasks names the problem's subtasks the test counts towards.
+	Subtasks []string `json:"subtasks,omitempty" bson:"subtasks,omitempty"`
+}
+
+type IOTestCaseDataAccessor interface {
+	CreateIOTestCase(ctx context.Context, ioTestCase *IOTestCase) error
+	GetIOTestCasesByProblemUUID(ctx context.Context, problemUUID string) ([]IOTestCase, error)
+	DeleteIOTestCase(ctx context.Context, ioTestCaseUUID string) error
+	DeleteIOTestCasesByProblemUUID(ctx context.Context, problemUUID string) error
+}
+
+type ioTestCaseDataAccessor struct {
+	db     *mongo.Collection
+	logger *zap.Logger
+}
+
+func (i *ioTestCaseDataAccessor) CreateIOTestCase(ctx context.Context, ioTestCase *IOTestCase) error {
+	_, err := i.db.InsertOne(ctx, ioTestCase)
+	if err != nil {
+		i.logger.Error("fail to create io test case in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (i *ioTestCaseDataAccessor) GetIOTestCasesByProblemUUID(ctx context.Context, problemUUID string) ([]IOTestCase, error) {
+	filter := bson.M{"ofProblemUUID": problemUUID}
+	opts := options.Find().SetSort(bson.D{{Key: "order", Value: 1}})
+	cursor, err := i.db.Find(ctx, filter, opts)
+	if err != nil {
+		i.logger.Error("failed to create cursor for io test cases", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return []IOTestCase{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var ioTestCases []IOTestCase
+	for cursor.Next(ctx) {
+		var ioTestCase IOTestCase
+		if err := cursor.Decode(&ioTestCase); err != nil {
+			i.logger.Error("failed to decode io test case", zap.Error(err))
+			return []IOTestCase{}, err
+		}
+		ioTestCases = append(ioTestCases, ioTestCase)
+	}
+
+	if err := cursor.Err(); err != nil {
+		i.logger.Error("cursor encountered an error", zap.Error(err))
+		return []IOTestCase{}, err
+	}
+	return ioTestCases, nil
+}
+
+func (i *ioTestCaseDataAccessor) DeleteIOTestCase(ctx context.Context, ioTestCaseUUID string) error {
+	filter := bson.M{"UUID": ioTestCaseUUID}
+	_, err := i.db.DeleteOne(ctx, filter)
+	if err != nil {
+		i.logger.Error("fail to delete io test case", zap.String("ioTestCaseUUID", ioTestCaseUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (i *ioTestCaseDataAccessor) DeleteIOTestCasesByProblemUUID(ctx context.Context, problemUUID string) error {
+	filter := bson.M{"ofProblemUUID": problemUUID}
+	_, err := i.db.DeleteMany(ctx, filter)
+	if err != nil {
+		i.logger.Error("fail to delete io test cases of problem", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func NewIOTestCaseDataAccessor(db *mongo.Collection, logger *zap.Logger) (IOTestCaseDataAccessor, error) {
+	return &ioTestCaseDataAccessor{db: db, logger: logger}, nil
+}
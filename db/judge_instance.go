@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// JudgeInstance records that a judge process is alive. Each instance renews
+// its heartbeat on every janitor pass, so the janitors of other instances can
+// tell the leftovers of a crashed instance from resources still in use.
+type JudgeInstance struct {
+	InstanceID  string `json:"instanceID" bson:"instanceID"`
+	Hostname    string `json:"hostname" bson:"hostname"`
+	HeartbeatAt int64  `json:"heartbeatAt" bson:"heartbeatAt"`
+}
+
+type JudgeInstanceDataAccessor interface {
+	Heartbeat(ctx context.Context, instanceID string, hostname string) error
+	// GetLiveInstanceIDs lists the instances whose last heartbeat came after
+	// heartbeatAfter.
+	GetLiveInstanceIDs(ctx context.Context, heartbeatAfter int64) ([]string, error)
+	DeleteStaleInstances(ctx context.Context, heartbeatBefore int64) error
+}
+
+type judgeInstanceDataAccessor struct {
+	db     *mongo.Collection
+	logger *zap.Logger
+}
+
+func (j *judgeInstanceDataAccessor) Heartbeat(ctx context.Context, instanceID string, hostname string) error {
+	filter := bson.M{"instanceID": instanceID}
+	update := bson.M{"$set": bson.M{
+		"hostname":    hostname,
+		"heartbeatAt": time.Now().UnixMilli(),
+	}}
+	_, err := j.db.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		j.logger.Error("fail to record judge instance heartbeat", zap.String("instanceID", instanceID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (j *judgeInstanceDataAccessor) GetLiveInstanceIDs(ctx context.Context, heartbeatAfter int64) ([]string, error) {
+	cursor, err := j.db.Find(ctx, bson.M{"heartbeatAt": bson.M{"$gt": heartbeatAfter}})
+	if err != nil {
+		j.logger.Error("fail to find live judge instances", zap.Error(err))
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var instances []JudgeInstance
+	if err := cursor.All(ctx, &instances); err != nil {
+		j.logger.Error("fail to decode judge instances", zap.Error(err))
+		return nil, err
+	}
+	instanceIDs := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		instanceIDs = append(instanceIDs, instance.InstanceID)
+	}
+	return instanceIDs, nil
+}
+
+func (j *judgeInstanceDataAccessor) DeleteStaleInstances(ctx context.Context, heartbeatBefore int64) error {
+	_, err := j.db.DeleteMany(ctx, bson.M{"heartbeatAt": bson.M{"$lt": heartbeatBefore}})
+	if err != nil {
+		j.logger.Error("fail to delete stale judge instances", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func NewJudgeInstanceDataAccessor(db *mongo.Collection, logger *zap.Logger) (JudgeInstanceDataAccessor, error) {
+	return &judgeInstanceDataAccessor{db: db, logger: logger}, nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var ErrJudgeLogNotFound = errors.New("judge log not found")
+
+// JudgeLogDataAccessor keeps the full output of judging a submission in
+// GridFS, since it can grow past what fits in the submission document.
+type JudgeLogDataAccessor interface {
+	// UploadJudgeLog stores the log and returns the ID of its file.
+	UploadJudgeLog(ctx context.Context, submissionUUID string, log string) (string, error)
+	// OpenJudgeLog opens the file with the given ID for reading. It returns
+	// ErrJudgeLogNotFound when there is no such file.
+	OpenJudgeLog(ctx context.Context, fileID string) (io.ReadCloser, error)
+}
+
+type judgeLogDataAccessor struct {
+	bucket *gridfs.Bucket
+	logger *zap.Logger
+}
+
+func (j *judgeLogDataAccessor) UploadJudgeLog(ctx context.Context, submissionUUID string, log string) (string, error) {
+	stream, err := j.bucket.OpenUploadStream(submissionUUID + ".log")
+	if err != nil {
+		j.logger.Error("fail to open judge log upload stream", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetWriteDeadline(deadline)
+	}
+	if _, err := io.Copy(stream, strings.NewReader(log)); err != nil {
+		stream.Abort()
+		j.logger.Error("fail to upload judge log", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return "", err
+	}
+	if err := stream.Close(); err != nil {
+		j.logger.Error("fail to upload judge log", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return "", err
+	}
+	return stream.FileID.(primitive.ObjectID).Hex(), nil
+}
+
+func (j *judgeLogDataAccessor) OpenJudgeLog(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	objectID, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return nil, ErrJudgeLogNotFound
+	}
+	stream, err := j.bucket.OpenDownloadStream(objectID)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, ErrJudgeLogNotFound
+		}
+		j.logger.Error("fail to open judge log", zap.String("fileID", fileID), zap.Error(err))
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetReadDeadline(deadline)
+	}
+	return stream, nil
+}
+
+func NewJudgeLogDataAccessor(database *mongo.Database, bucketName string, logger *zap.Logger) (JudgeLogDataAccessor, error) {
+	bucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		logger.Error("fail to create judge log bucket", zap.String("bucket", bucketName), zap.Error(err))
+		return nil, err
+	}
+	return &judgeLogDataAccessor{bucket: bucket, logger: logger}, nil
+}
@@ -31,6 +31,13 @@ type SubmissionSnippetData struct {
 	SubmissionSnippetUUID string `json:"submissionSnippetUUID" bson:"submissionSnippetUUID" validate:"required"`
 	Language              string `json:"language" bson:"language" validate:"required"`
 }
+
+const (
+	ProblemJudgeModeTestHarness = "test_harness"
+	ProblemJudgeModeStandardIO  = "standard_io"
+	ProblemJudgeModeInteractive = "interactive"
+)
+
 type Problem struct {
 	UUID                   string                  `json:"UUID" bson:"UUID" validate:"required"`
 	DisplayName            string                  `json:"displayName" bson:"displayName" validate:"required"`
@@ -43,6 +50,33 @@ type Problem struct {
 	CreatedAt              string                  `json:"createdAt" bson:"createdAt"`
 	UpdatedAt              string                  `json:"updatedAt" bson:"updatedAt"`
 	SubmissionSnippetList  []SubmissionSnippetData `json:"submissionSnippetList" bson:"submissionSnippetList"`
+	JudgeMode              string                  `json:"judgeMode" bson:"judgeMode"`
+	// Checker judges standard input/output answers in place of the exact
+	// comparison, for problems with more than one correct answer.
+	Checker *ProblemProgram `json:"checker,omitempty" bson:"checker,omitempty"`
+	// Interactor talks to the submission over its stdin and stdout and
+	// decides the verdict of an interactive problem.
+	Interactor *ProblemProgram `json:"interactor,omitempty" bson:"interactor,omitempty"`
+	// Subtasks split the problem's tests into weighted groups. Without
+	// them a submission scores all or nothing.
+	Subtasks []Subtask `json:"subtasks,omitempty" bson:"subtasks,omitempty"`
+}
+
+// Subtask is a weighted group of tests, scored IOI-style: a submission only
+// gets its Points when every test of the group passes. Standard input/output
+// and interactive tests name their subtasks themselves, while a test harness
+// subtask lists its test methods in Tests. A test may belong to several
+// subtasks.
+type Subtask struct {
+	Name   string   `json:"name" bson:"name"`
+	Points int64    `json:"points" bson:"points"`
+	Tests  []string `json:"tests,omitempty" bson:"tests,omitempty"`
+}
+
+// ProblemProgram is a testlib-compatible program supplied by the setter.
+type ProblemProgram struct {
+	Language string `json:"language" bson:"language"`
+	Source   string `json:"source" bson:"source"`
 }
 
 func (p *problemDataAccessor) DeleteProblem(ctx context.Context, problemUUID string) error {
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// ProblemFixture is a file, such as a data file, a helper class or an
+// expected output, that every run of the problem finds read-only next to the
+// submission. Name is a relative path within the work directory.
+type ProblemFixture struct {
+	UUID          string `json:"UUID" bson:"UUID"`
+	OfProblemUUID string `json:"ofProblemUUID" bson:"ofProblemUUID"`
+	Name          string `json:"name" bson:"name"`
+	Content       []byte `json:"-" bson:"content"`
+	SizeInByte    int64  `json:"sizeInByte" bson:"sizeInByte"`
+	CreatedAt     string `json:"createdAt" bson:"createdAt"`
+}
+
+type ProblemFixtureDataAccessor interface {
+	// SaveProblemFixture stores the fixture, replacing the problem's fixture
+	// of the same name if there is one.
+	SaveProblemFixture(ctx context.Context, fixture *ProblemFixture) error
+	GetProblemFixturesByProblemUUID(ctx context.Context, problemUUID string) ([]ProblemFixture, error)
+	// GetProblemFixturesSize returns the total size of the problem's
+	// fixtures, leaving out the one named exceptName.
+	GetProblemFixturesSize(ctx context.Context, problemUUID string, exceptName string) (int64, error)
+	DeleteProblemFixture(ctx context.Context, fixtureUUID string) error
+	DeleteProblemFixturesByProblemUUID(ctx context.Context, problemUUID string) error
+}
+
+type problemFixtureDataAccessor struct {
+	db     *mongo.Collection
+	logger *zap.Logger
+}
+
+func (p *problemFixtureDataAccessor) SaveProblemFixture(ctx context.Context, fixture *ProblemFixture) error {
+	filter := bson.M{"ofProblemUUID": fixture.OfProblemUUID, "name": fixture.Name}
+	_, err := p.db.ReplaceOne(ctx, filter, fixture, options.Replace().SetUpsert(true))
+	if err != nil {
+		p.logger.Error("fail to save problem fixture in database", zap.String("problemUUID", fixture.OfProblemUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p *problemFixtureDataAccessor) GetProblemFixturesByProblemUUID(ctx context.Context, problemUUID string) ([]ProblemFixture, error) {
+	filter := bson.M{"ofProblemUUID": problemUUID}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := p.db.Find(ctx, filter, opts)
+	if err != nil {
+		p.logger.Error("failed to create cursor for problem fixtures", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return []ProblemFixture{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var fixtures []ProblemFixture
+	if err := cursor.All(ctx, &fixtures); err != nil {
+		p.logger.Error("failed to decode problem fixtures", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return []ProblemFixture{}, err
+	}
+	return fixtures, nil
+}
+
+func (p *problemFixtureDataAccessor) GetProblemFixturesSize(ctx context.Context, problemUUID string, exceptName string) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"ofProblemUUID": problemUUID, "name": bson.M{"$ne": exceptName}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "size": bson.M{"$sum": "$sizeInByte"}}}},
+	}
+	cursor, err := p.db.Aggregate(ctx, pipeline)
+	if err != nil {
+		p.logger.Error("fail to sum problem fixture sizes", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Size int64 `bson:"size"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		p.logger.Error("fail to decode problem fixture sizes", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return totals[0].Size, nil
+}
+
+func (p *problemFixtureDataAccessor) DeleteProblemFixture(ctx context.Context, fixtureUUID string) error {
+	filter := bson.M{"UUID": fixtureUUID}
+	_, err := p.db.DeleteOne(ctx, filter)
+	if err != nil {
+		p.logger.Error("fail to delete problem fixture", zap.String("fixtureUUID", fixtureUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p *problemFixtureDataAccessor) DeleteProblemFixturesByProblemUUID(ctx context.Context, problemUUID string) error {
+	filter := bson.M{"ofProblemUUID": problemUUID}
+	_, err := p.db.DeleteMany(ctx, filter)
+	if err != nil {
+		p.logger.Error("fail to delete problem fixtures", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func NewProblemFixtureDataAccessor(db *mongo.Collection, logger *zap.Logger) (ProblemFixtureDataAccessor, error) {
+	return &problemFixtureDataAccessor{db: db, logger: logger}, nil
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,6 +17,7 @@ const (
 	SubmissionStatusSubmitted SubmissionStatus = 1
 	SubmissionStatusExecuting SubmissionStatus = 2
 	SubmissionStatusFinished  SubmissionStatus = 3
+	SubmissionStatusCancelled SubmissionStatus = 4
 
 	SubmissionResultOK                  SubmissionResult = 1
 	SubmissionResultCompileError        SubmissionResult = 2
@@ -24,18 +26,109 @@ const (
 	SubmissionResultMemoryLimitExceed   SubmissionResult = 5
 	SubmissionResultWrongAnswer         SubmissionResult = 6
 	SubmissionResultUnsupportedLanguage SubmissionResult = 7
+	SubmissionResultOutputLimitExceeded SubmissionResult = 8
+	// SubmissionResultInternalError is given when the judge fails to grade
+	// the submission, through no fault of its own.
+	SubmissionResultInternalError SubmissionResult = 9
 )
 
+// TestResult is the outcome of a single test method within a submission's run.
+type TestResult struct {
+	Name              string           `json:"name" bson:"name"`
+	Result            SubmissionResult `json:"result" bson:"result"`
+	TimeInMillisecond int64            `json:"timeInMillisecond" bson:"timeInMillisecond"`
+	MemoryInByte      uint64           `json:"memoryInByte" bson:"memoryInByte"`
+	Message           string           `json:"message" bson:"message"`
+	// Expected and Actual are the values a failed assertion compared, when
+	// its message names them.
+	Expected string `json:"expected,omitempty" bson:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty" bson:"actual,omitempty"`
+	// Hidden tests only show contestants their verdict.
+	Hidden bool `json:"hidden,omitempty" bson:"hidden,omitempty"`
+	// Subtasks names the problem's subtasks the test counts towards.
+	Subtasks []string `json:"subtasks,omitempty" bson:"subtasks,omitempty"`
+}
+
+// TestSummary counts the tests of a harness run by outcome.
+type TestSummary struct {
+	Total   int `json:"total" bson:"total"`
+	Passed  int `json:"passed" bson:"passed"`
+	Failed  int `json:"failed" bson:"failed"`
+	Errors  int `json:"errors" bson:"errors"`
+	Skipped int `json:"skipped" bson:"skipped"`
+}
+
+// SubtaskScore is what a submission scored on one subtask of its problem.
+// Result is the verdict of the subtask's worst test.
+type SubtaskScore struct {
+	Name   string           `json:"name" bson:"name"`
+	Points int64            `json:"points" bson:"points"`
+	Score  int64            `json:"score" bson:"score"`
+	Result SubmissionResult `json:"result" bson:"result"`
+}
+
 type Submission struct {
 	UUID              string           `json:"UUID" bson:"UUID"`
 	ProblemUUID       string           `json:"problemUUID" bson:"problemUUID" validate:"required"`
 	AuthorAccountUUID string           `json:"authorAccountUUID" bson:"authorAccountUUID" validate:"required"`
 	Content           string           `json:"content" bson:"content" validate:"required,min=1,max=64000"`
 	Language          string           `json:"language" bson:"language" validate:"required,max=32"`
-	Status            SubmissionStatus `json:"status" bson:"status" validate:"required,oneof=1 2 3"`
-	Result            SubmissionResult `json:"result" bson:"result" validate:"oneof=1 2 3 4 5 6 7"`
+	Status            SubmissionStatus `json:"status" bson:"status" validate:"required,oneof=1 2 3 4"`
+	Result            SubmissionResult `json:"result" bson:"result" validate:"oneof=1 2 3 4 5 6 7 8 9"`
 	GradingResult     string           `json:"grading_result" bson:"grading_result"`
-	CreatedTime       int64            `json:"created_time" bson:"created_time"`
+	TestResults       []TestResult     `json:"testResults" bson:"testResults"`
+	TestSummary       *TestSummary     `json:"testSummary,omitempty" bson:"testSummary,omitempty"`
+	// Score is out of MaxScore, the problem's total points when the
+	// submission was judged. SubtaskScores breaks it down by subtask.
+	Score         int64          `json:"score" bson:"score"`
+	MaxScore      int64          `json:"maxScore" bson:"maxScore"`
+	SubtaskScores []SubtaskScore `json:"subtaskScores,omitempty" bson:"subtaskScores,omitempty"`
+	// Resource usage of the slowest and hungriest run of the submission.
+	TimeInMillisecond     int64  `json:"timeInMillisecond" bson:"timeInMillisecond"`
+	WallTimeInMillisecond int64  `json:"wallTimeInMillisecond" bson:"wallTimeInMillisecond"`
+	MemoryInByte          uint64 `json:"memoryInByte" bson:"memoryInByte"`
+	CreatedTime           int64  `json:"created_time" bson:"created_time"`
+	JudgedTime            int64  `json:"judged_time" bson:"judged_time"`
+	// RequeuedTime is when the janitor last claimed the submission to give
+	// it a new job.
+	RequeuedTime int64 `json:"requeued_time,omitempty" bson:"requeued_time,omitempty"`
+	// LogFileID names the GridFS file holding the full output of the
+	// latest judging, of which GradingResult may only keep the start.
+	LogFileID string `json:"logFileID,omitempty" bson:"logFileID,omitempty"`
+	// HidesTestDetails is set when GradingResult may show details of hidden
+	// tests, so contestants get it rebuilt from the sample tests instead.
+	HidesTestDetails bool `json:"hidesTestDetails,omitempty" bson:"hidesTestDetails,omitempty"`
+	// VerdictHistory keeps every verdict a rejudge replaced, oldest first.
+	VerdictHistory []SubmissionVerdict `json:"verdictHistory" bson:"verdictHistory,omitempty"`
+	// Files are the submission's sources besides Content, such as the other
+	// classes of a Java package.
+	Files []SubmissionFile `json:"files" bson:"files,omitempty"`
+}
+
+// SubmissionFile is a source file of a submission. Name is a relative path
+// within the work directory.
+type SubmissionFile struct {
+	Name    string `json:"name" bson:"name"`
+	Content string `json:"content" bson:"content"`
+}
+
+// SubmissionVerdict is a verdict the submission held before it was rejudged.
+type SubmissionVerdict struct {
+	Result                SubmissionResult `json:"result" bson:"result"`
+	GradingResult         string           `json:"grading_result" bson:"grading_result"`
+	TestResults           []TestResult     `json:"testResults" bson:"testResults"`
+	TestSummary           *TestSummary     `json:"testSummary,omitempty" bson:"testSummary,omitempty"`
+	Score                 int64            `json:"score" bson:"score"`
+	MaxScore              int64            `json:"maxScore" bson:"maxScore"`
+	SubtaskScores         []SubtaskScore   `json:"subtaskScores,omitempty" bson:"subtaskScores,omitempty"`
+	TimeInMillisecond     int64            `json:"timeInMillisecond" bson:"timeInMillisecond"`
+	WallTimeInMillisecond int64            `json:"wallTimeInMillisecond" bson:"wallTimeInMillisecond"`
+	MemoryInByte          uint64           `json:"memoryInByte" bson:"memoryInByte"`
+	JudgedTime            int64            `json:"judged_time" bson:"judged_time"`
+	ReplacedTime          int64            `json:"replaced_time" bson:"replaced_time"`
+	RejudgedBy            string           `json:"rejudgedBy" bson:"rejudgedBy"`
+	LogFileID             string           `json:"logFileID,omitempty" bson:"logFileID,omitempty"`
+	HidesTestDetails      bool             `json:"hidesTestDetails,omitempty" bson:"hidesTestDetails,omitempty"`
 }
 
 type submissionDataAccessor struct {
@@ -48,6 +141,10 @@ type SubmissionDataAccessor interface {
 	GetSubmissionByUUID(ctx context.Context, uuid string) (*Submission, error)
 	UpdateSubmissionByUUID(ctx context.Context, uuid string, update map[string]any) error
 	GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string) ([]*Submission, error)
+	GetSubmissionsByProblem(ctx context.Context, problemUUID string, language string) ([]*Submission, error)
+	ReplaceSubmissionVerdict(ctx context.Context, uuid string, update map[string]any, previous SubmissionVerdict) error
+	GetUnfinishedSubmissions(ctx context.Context, createdBefore int64) ([]*Submission, error)
+	ClaimOrphanedSubmission(ctx context.Context, uuid string, previousRequeuedTime int64) (bool, error)
 }
 
 func (s *submissionDataAccessor) CreateSubmission(ctx context.Context, submission *Submission) error {
@@ -93,6 +190,26 @@ func (s *submissionDataAccessor) UpdateSubmissionByUUID(ctx context.Context, uui
 	return nil
 }
 
+// ReplaceSubmissionVerdict sets the new verdict of a rejudged submission and
+// appends the one it replaces to its history in a single update.
+func (s *submissionDataAccessor) ReplaceSubmissionVerdict(ctx context.Context, uuid string, update map[string]any, previous SubmissionVerdict) error {
+	updateAsBson := bson.M{
+		"$set":  update,
+		"$push": bson.M{"verdictHistory": previous},
+	}
+	filter := bson.M{"UUID": uuid}
+	result, err := s.db.UpdateOne(ctx, filter, updateAsBson)
+	if err != nil {
+		s.logger.Error("fail to replace submission verdict", zap.String("submission UUID", uuid), zap.Error(err))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		s.logger.Error("fail to replace submission verdict", zap.String("submission UUID", uuid))
+		return fmt.Errorf("no submission found with UUID: %s", uuid)
+	}
+	return nil
+}
+
 func (s *submissionDataAccessor) GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string) ([]*Submission, error) {
 	filter := bson.M{
 		"problemUUID":       problemUUID,
@@ -131,6 +248,82 @@ func (s *submissionDataAccessor) GetSubmissionsByProblemAndAuthor(ctx context.Co
 	return submissions, nil
 }
 
+// GetSubmissionsByProblem lists the submissions of a problem, restricted to
+// one language unless language is empty.
+func (s *submissionDataAccessor) GetSubmissionsByProblem(ctx context.Context, problemUUID string, language string) ([]*Submission, error) {
+	filter := bson.M{"problemUUID": problemUUID}
+	if language != "" {
+		filter["language"] = language
+	}
+
+	s.logger.Info("getting submissions by problem UUID",
+		zap.String("problemUUID", problemUUID),
+		zap.String("language", language))
+
+	cursor, err := s.db.Find(ctx, filter)
+	if err != nil {
+		s.logger.Error("fail to find submissions",
+			zap.String("problemUUID", problemUUID),
+			zap.String("language", language),
+			zap.Error(err))
+		return []*Submission{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var submissions []*Submission
+	if err := cursor.All(ctx, &submissions); err != nil {
+		s.logger.Error("fail to decode submissions", zap.Error(err))
+		return []*Submission{}, err
+	}
+	return submissions, nil
+}
+
+// GetUnfinishedSubmissions lists the submissions created before createdBefore
+// that are still waiting for or in the middle of judging.
+func (s *submissionDataAccessor) GetUnfinishedSubmissions(ctx context.Context, createdBefore int64) ([]*Submission, error) {
+	filter := bson.M{
+		"status":       bson.M{"$in": []SubmissionStatus{SubmissionStatusSubmitted, SubmissionStatusExecuting}},
+		"created_time": bson.M{"$lt": createdBefore},
+	}
+	cursor, err := s.db.Find(ctx, filter)
+	if err != nil {
+		s.logger.Error("fail to find unfinished submissions", zap.Error(err))
+		return []*Submission{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var submissions []*Submission
+	if err := cursor.All(ctx, &submissions); err != nil {
+		s.logger.Error("fail to decode submissions", zap.Error(err))
+		return []*Submission{}, err
+	}
+	return submissions, nil
+}
+
+// ClaimOrphanedSubmission marks the submission as requeued, as long as
+// nobody else has done so since it was read with previousRequeuedTime. Only
+// the janitor whose claim succeeds enqueues a new job for it.
+func (s *submissionDataAccessor) ClaimOrphanedSubmission(ctx context.Context, uuid string, previousRequeuedTime int64) (bool, error) {
+	filter := bson.M{
+		"UUID":          uuid,
+		"status":        bson.M{"$in": []SubmissionStatus{SubmissionStatusSubmitted, SubmissionStatusExecuting}},
+		"requeued_time": previousRequeuedTime,
+	}
+	if previousRequeuedTime == 0 {
+		filter["requeued_time"] = bson.M{"$exists": false}
+	}
+	update := bson.M{"$set": bson.M{
+		"status":        SubmissionStatusSubmitted,
+		"requeued_time": time.Now().UnixMilli(),
+	}}
+	result, err := s.db.UpdateOne(ctx, filter, update)
+	if err != nil {
+		s.logger.Error("fail to claim orphaned submission", zap.String("submissionUUID", uuid), zap.Error(err))
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
 func NewSubmissionDataAccessor(db *mongo.Collection, logger *zap.Logger) (SubmissionDataAccessor, error) {
 	return &submissionDataAccessor{db: db, logger: logger}, nil
 }
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type SubmissionJobStatus uint8
+
+const (
+	SubmissionJobStatusQueued    SubmissionJobStatus = 1
+	SubmissionJobStatusLeased    SubmissionJobStatus = 2
+	SubmissionJobStatusDone      SubmissionJobStatus = 3
+	SubmissionJobStatusFailed    SubmissionJobStatus = 4
+	SubmissionJobStatusCancelled SubmissionJobStatus = 5
+
+	// Jobs are claimed highest priority first, so rejudges only run once no
+	// fresh submission is waiting.
+	SubmissionJobPriorityNormal  = 0
+	SubmissionJobPriorityRejudge = -10
+)
+
+// SubmissionJob is a durable queue entry for a submission waiting to be judged.
+// A judge process leases a job by setting LeaseOwner and LeaseExpiresAt; if the
+// process dies before finishing, the lease expires and the job becomes visible
+// to other judges again.
+type SubmissionJob struct {
+	UUID           string              `json:"UUID" bson:"UUID"`
+	SubmissionUUID string              `json:"submissionUUID" bson:"submissionUUID"`
+	Language       string              `json:"language" bson:"language"`
+	Status         SubmissionJobStatus `json:"status" bson:"status"`
+	Priority       int                 `json:"priority" bson:"priority"`
+	Attempts       int                 `json:"attempts" bson:"attempts"`
+	LeaseOwner     string              `json:"leaseOwner" bson:"leaseOwner"`
+	LeaseExpiresAt int64               `json:"leaseExpiresAt" bson:"leaseExpiresAt"`
+	AvailableAt    int64               `json:"availableAt" bson:"availableAt"`
+	LastError      string              `json:"lastError" bson:"lastError"`
+	CreatedTime    int64               `json:"created_time" bson:"created_time"`
+	UpdatedTime    int64               `json:"updated_time" bson:"updated_time"`
+	// Rejudge jobs judge a finished submission again, keeping the verdict
+	// they replace in its history.
+	Rejudge     bool   `json:"rejudge" bson:"rejudge"`
+	RequestedBy string `json:"requestedBy" bson:"requestedBy"`
+	// CancelRequested asks the judge holding the lease to stop the job. It
+	// sees the request on its next lease extension.
+	CancelRequested bool `json:"cancelRequested" bson:"cancelRequested"`
+}
+
+type SubmissionJobDataAccessor interface {
+	EnqueueJob(ctx context.Context, job *SubmissionJob) error
+	ClaimNextJob(ctx context.Context, leaseOwner string, leaseDuration time.Duration, excludedLanguages []string) (*SubmissionJob, error)
+	ExtendLease(ctx context.Context, jobUUID string, leaseOwner string, leaseDuration time.Duration) (bool, error)
+	CompleteJob(ctx context.Context, jobUUID string, leaseOwner string) error
+	RetryJob(ctx context.Context, jobUUID string, leaseOwner string, retryDelay time.Duration, lastError string) error
+	FailJob(ctx context.Context, jobUUID string, leaseOwner string, lastError string) error
+	ReleaseJob(ctx context.Context, jobUUID string, leaseOwner string, delay time.Duration) error
+	CancelJobs(ctx context.Context, submissionUUID string) (int64, int64, error)
+	FinishCancelledJob(ctx context.Context, jobUUID string, leaseOwner string) error
+	HasActiveJob(ctx context.Context, submissionUUID string) (bool, error)
+}
+
+type submissionJobDataAccessor struct {
+	db     *mongo.Collection
+	logger *zap.Logger
+}
+
+func (s *submissionJobDataAccessor) EnqueueJob(ctx context.Context, job *SubmissionJob) error {
+	now := time.Now().UnixMilli()
+	job.Status = SubmissionJobStatusQueued
+	if job.AvailableAt == 0 {
+		job.AvailableAt = now
+	}
+	job.CreatedTime = now
+	job.UpdatedTime = now
+	_, err := s.db.InsertOne(ctx, job)
+	if err != nil {
+		s.logger.Error("fail to enqueue submission job", zap.String("submissionUUID", job.SubmissionUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ClaimNextJob atomically leases the highest priority job that is either queued
+// and due, or whose previous lease has expired, skipping jobs in excludedLanguages.
+// It returns nil when the queue is empty.
+func (s *submissionJobDataAccessor) ClaimNextJob(ctx context.Context, leaseOwner string, leaseDuration time.Duration, excludedLanguages []string) (*SubmissionJob, error) {
+	now := time.Now().UnixMilli()
+	filter := bson.M{
+		"$or": []bson.M{
+			{"status": SubmissionJobStatusQueued, "availableAt": bson.M{"$lte": now}},
+			{"status": SubmissionJobStatusLeased, "leaseExpiresAt": bson.M{"$lte": now}},
+		},
+	}
+	if len(excludedLanguages) > 0 {
+		filter["language"] = bson.M{"$nin": excludedLanguages}
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":         SubmissionJobStatusLeased,
+			"leaseOwner":     leaseOwner,
+			"leaseExpiresAt": now + leaseDuration.Milliseconds(),
+			"updated_time":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_time", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job SubmissionJob
+	err := s.db.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		s.logger.Error("fail to claim submission job", zap.Error(err))
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ExtendLease renews the lease and reports whether the job has been asked to stop.
+func (s *submissionJobDataAccessor) ExtendLease(ctx context.Context, jobUUID string, leaseOwner string, leaseDuration time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	filter := bson.M{
+		"UUID":       jobUUID,
+		"status":     SubmissionJobStatusLeased,
+		"leaseOwner": leaseOwner,
+	}
+	update := bson.M{"$set": bson.M{
+		"leaseExpiresAt": now + leaseDuration.Milliseconds(),
+		"updated_time":   now,
+	}}
+	var job SubmissionJob
+	err := s.db.FindOneAndUpdate(ctx, filter, update).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			s.logger.Warn("submission job lease lost", zap.String("jobUUID", jobUUID), zap.String("leaseOwner", leaseOwner))
+			return false, fmt.Errorf("lease on submission job %s is no longer held by %s", jobUUID, leaseOwner)
+		}
+		s.logger.Error("fail to extend submission job lease", zap.String("jobUUID", jobUUID), zap.Error(err))
+		return false, err
+	}
+	return job.CancelRequested, nil
+}
+
+func (s *submissionJobDataAccessor) CompleteJob(ctx context.Context, jobUUID string, leaseOwner string) error {
+	return s.updateLeasedJob(ctx, jobUUID, leaseOwner, bson.M{"$set": bson.M{
+		"status":       SubmissionJobStatusDone,
+		"leaseOwner":   "",
+		"updated_time": time.Now().UnixMilli(),
+	}})
+}
+
+func (s *submissionJobDataAccessor) RetryJob(ctx context.Context, jobUUID string, leaseOwner string, retryDelay time.Duration, lastError string) error {
+	now := time.Now().UnixMilli()
+	return s.updateLeasedJob(ctx, jobUUID, leaseOwner, bson.M{"$set": bson.M{
+		"status":       SubmissionJobStatusQueued,
+		"leaseOwner":   "",
+		"availableAt":  now + retryDelay.Milliseconds(),
+		"lastError":    lastError,
+		"updated_time": now,
+	}})
+}
+
+func (s *submissionJobDataAccessor) FailJob(ctx context.Context, jobUUID string, leaseOwner string, lastError string) error {
+	return s.updateLeasedJob(ctx, jobUUID, leaseOwner, bson.M{"$set": bson.M{
+		"status":       SubmissionJobStatusFailed,
+		"leaseOwner":   "",
+		"lastError":    lastError,
+		"updated_time": time.Now().UnixMilli(),
+	}})
+}
+
+// ReleaseJob hands a claimed job back to the queue without counting the claim
+// as an attempt, for a judge that turns out to have no capacity for it.
+func (s *submissionJobDataAccessor) ReleaseJob(ctx context.Context, jobUUID string, leaseOwner string, delay time.Duration) error {
+	now := time.Now().UnixMilli()
+	return s.updateLeasedJob(ctx, jobUUID, leaseOwner, bson.M{
+		"$set": bson.M{
+			"status":       SubmissionJobStatusQueued,
+			"leaseOwner":   "",
+			"availableAt":  now + delay.Milliseconds(),
+			"updated_time": now,
+		},
+		"$inc": bson.M{"attempts": -1},
+	})
+}
+
+// CancelJobs removes the queued jobs of a submission and asks the judges
+// running its leased jobs to stop. It returns how many jobs were removed and
+// how many are still running.
+func (s *submissionJobDataAccessor) CancelJobs(ctx context.Context, submissionUUID string) (int64, int64, error) {
+	deleted, err := s.db.DeleteMany(ctx, bson.M{
+		"submissionUUID": submissionUUID,
+		"status":         SubmissionJobStatusQueued,
+	})
+	if err != nil {
+		s.logger.Error("fail to remove queued submission jobs", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return 0, 0, err
+	}
+	updated, err := s.db.UpdateMany(ctx, bson.M{
+		"submissionUUID": submissionUUID,
+		"status":         SubmissionJobStatusLeased,
+	}, bson.M{"$set": bson.M{
+		"cancelRequested": true,
+		"updated_time":    time.Now().UnixMilli(),
+	}})
+	if err != nil {
+		s.logger.Error("fail to request submission job cancellation", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return deleted.DeletedCount, 0, err
+	}
+	return deleted.DeletedCount, updated.MatchedCount, nil
+}
+
+func (s *submissionJobDataAccessor) FinishCancelledJob(ctx context.Context, jobUUID string, leaseOwner string) error {
+	return s.updateLeasedJob(ctx, jobUUID, leaseOwner, bson.M{"$set": bson.M{
+		"status":       SubmissionJobStatusCancelled,
+		"leaseOwner":   "",
+		"updated_time": time.Now().UnixMilli(),
+	}})
+}
+
+// HasActiveJob reports whether the submission has a job that is queued or
+// leased, including one whose lease expired and is waiting to be claimed again.
+func (s *submissionJobDataAccessor) HasActiveJob(ctx context.Context, submissionUUID string) (bool, error) {
+	count, err := s.db.CountDocuments(ctx, bson.M{
+		"submissionUUID": submissionUUID,
+		"status":         bson.M{"$in": []SubmissionJobStatus{SubmissionJobStatusQueued, SubmissionJobStatusLeased}},
+	})
+	if err != nil {
+		s.logger.Error("fail to count submission jobs", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// updateLeasedJob only touches the job while the caller still holds its lease,
+// so a judge whose lease expired can't overwrite the work of the judge that took over.
+func (s *submissionJobDataAccessor) updateLeasedJob(ctx context.Context, jobUUID string, leaseOwner string, update bson.M) error {
+	filter := bson.M{
+		"UUID":       jobUUID,
+		"status":     SubmissionJobStatusLeased,
+		"leaseOwner": leaseOwner,
+	}
+	result, err := s.db.UpdateOne(ctx, filter, update)
+	if err != nil {
+		s.logger.Error("fail to update submission job", zap.String("jobUUID", jobUUID), zap.Error(err))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		s.logger.Warn("submission job lease lost", zap.String("jobUUID", jobUUID), zap.String("leaseOwner", leaseOwner))
+		return fmt.Errorf("lease on submission job %s is no longer held by %s", jobUUID, leaseOwner)
+	}
+	return nil
+}
+
+func NewSubmissionJobDataAccessor(db *mongo.Collection, logger *zap.Logger) (SubmissionJobDataAccessor, error) {
+	return &submissionJobDataAccessor{db: db, logger: logger}, nil
+}
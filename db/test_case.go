@@ -14,6 +14,17 @@ type TestCase struct {
 	TestFileContent string `json:"testFileContent" bson:"testFileContent"`
 	CreatedAt       string `json:"createdAt" bson:"createdAt"`
 	Language        string `json:"language" bson:"language"`
+	TestVisibility  `bson:",inline"`
+}
+
+// TestVisibility marks the tests of a test file as samples, whose details
+// contestants see in full, or hidden, for which they only see the verdict.
+// IsHidden applies to the whole file; HiddenTests and SampleTests name single
+// test methods that differ from it.
+type TestVisibility struct {
+	IsHidden    bool     `json:"isHidden" bson:"isHidden"`
+	HiddenTests []string `json:"hiddenTests,omitempty" bson:"hiddenTests,omitempty"`
+	SampleTests []string `json:"sampleTests,omitempty" bson:"sampleTests,omitempty"`
 }
 
 type TestCaseDataAccessor interface {
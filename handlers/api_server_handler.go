@@ -51,6 +51,9 @@ type apiServerHandler struct {
 	testCaseAndSubmissionSnippetLogic logic.TestCaseAndSubmissionSnippet
 	accountLogic                      logic.Account
 	tokenLogic                        logic.Token
+	ioTestCaseLogic                   logic.IOTestCase
+	problemFixtureLogic               logic.ProblemFixture
+	judgeLogic                        logic.Judge
 }
 
 func NewAPIServerHandler(submissionLogic logic.Submission,
@@ -61,6 +64,9 @@ func NewAPIServerHandler(submissionLogic logic.Submission,
 	testCaseAndSubmissionSnippet logic.TestCaseAndSubmissionSnippet,
 	accountLogic logic.Account,
 	tokenLogic logic.Token,
+	ioTestCaseLogic logic.IOTestCase,
+	problemFixtureLogic logic.ProblemFixture,
+	judgeLogic logic.Judge,
 	logger *zap.Logger) *apiServerHandler {
 	return &apiServerHandler{
 		submissionLogic:                   submissionLogic,
@@ -72,6 +78,9 @@ func NewAPIServerHandler(submissionLogic logic.Submission,
 		testCaseAndSubmissionSnippetLogic: testCaseAndSubmissionSnippet,
 		tokenLogic:                        tokenLogic,
 		accountLogic:                      accountLogic,
+		ioTestCaseLogic:                   ioTestCaseLogic,
+		problemFixtureLogic:               problemFixtureLogic,
+		judgeLogic:                        judgeLogic,
 	}
 }
 
@@ -103,14 +112,23 @@ func (s *apiServerHandler) Start() {
 
 	router.HandleFunc("/submission", makeHTTPHandleFunc(s.handleSubmission))
 	router.HandleFunc("/submission/{submissionUUID}", makeHTTPHandleFunc(s.handleSubmission))
+	router.HandleFunc("/submission-cancel/{submissionUUID}", makeHTTPHandleFunc(s.handleSubmissionCancel))
+	router.HandleFunc("/submission-log/{submissionUUID}", makeHTTPHandleFunc(s.handleSubmissionLog))
 	router.HandleFunc("/submission-list/{problemUUID}/{authorAccountUUID}", makeHTTPHandleFunc(s.handleSubmissionList))
 	router.HandleFunc("/test-case/{testUUID}", makeHTTPHandleFunc(s.handleTestCase))
 	router.HandleFunc("/test-case-list/{problemUUID}", makeHTTPHandleFunc(s.handleTestCaseList))
 	router.HandleFunc("/test-case", makeHTTPHandleFunc(s.handleTestCase))
+	router.HandleFunc("/io-test-case", makeHTTPHandleFunc(s.handleIOTestCase))
+	router.HandleFunc("/io-test-case/{ioTestCaseUUID}", makeHTTPHandleFunc(s.handleIOTestCase))
+	router.HandleFunc("/io-test-case-list/{problemUUID}", makeHTTPHandleFunc(s.handleIOTestCaseList))
+	router.HandleFunc("/problem-fixture", makeHTTPHandleFunc(s.handleProblemFixture))
+	router.HandleFunc("/problem-fixture/{problemFixtureUUID}", makeHTTPHandleFunc(s.handleProblemFixture))
+	router.HandleFunc("/problem-fixture-list/{problemUUID}", makeHTTPHandleFunc(s.handleProblemFixtureList))
 	router.HandleFunc("/problem/{problemUUID}", makeHTTPHandleFunc(s.handleProblem))
 	router.HandleFunc("/test-case-and-submission-snippet", makeHTTPHandleFunc(s.handleProblemTestCaseAndSubmissionSnippet))
 	router.HandleFunc("/problem", makeHTTPHandleFunc(s.handleProblem))
 	router.HandleFunc("/problem-list", makeHTTPHandleFunc(s.handleProblemList))
+	router.HandleFunc("/languages", makeHTTPHandleFunc(s.handleLanguageList))
 	router.HandleFunc("/submission-snippet/{submissionSnippetUUID}", makeHTTPHandleFunc(s.handleSubmissionSnippet))
 	router.HandleFunc("/submission-snippet", makeHTTPHandleFunc(s.handleSubmissionSnippet))
 
@@ -118,6 +136,13 @@ func (s *apiServerHandler) Start() {
 	router.HandleFunc("/account/{accountUUID}", makeHTTPHandleFunc(s.handleAccount))
 	router.HandleFunc("/account-list", makeHTTPHandleFunc(s.handleAccountList))
 	router.HandleFunc("/login", makeHTTPHandleFunc(s.handleSession))
+	router.HandleFunc("/problem-checker/{problemUUID}", makeHTTPHandleFunc(s.handleProblemChecker))
+	router.HandleFunc("/problem-interactor/{problemUUID}", makeHTTPHandleFunc(s.handleProblemInteractor))
+	router.HandleFunc("/problem-subtasks/{problemUUID}", makeHTTPHandleFunc(s.handleProblemSubtasks))
+	router.HandleFunc("/run-code", makeHTTPHandleFunc(s.handleRunCode))
+	router.HandleFunc("/rejudge", makeHTTPHandleFunc(s.handleRejudge))
+	router.HandleFunc("/container-pool-health", makeHTTPHandleFunc(s.handleContainerPoolHealth))
+	router.HandleFunc("/language-readiness", makeHTTPHandleFunc(s.handleLanguageReadiness))
 	log.Fatal(http.ListenAndServe(address, router))
 	// srv := &http.Server{
 	// 	Addr:    address,
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (s *apiServerHandler) handleContainerPoolHealth(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.GetContainerPoolHealth(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) GetContainerPoolHealth(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(ctx, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+
+	switch role {
+	case RoleAdmin:
+		break
+	default:
+		return WriteJSON(w, http.StatusForbidden, "Insufficient permissions")
+	}
+
+	res, err := s.judgeLogic.GetContainerPoolHealth(ctx)
+	if err != nil {
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+
+	return WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/server/handlers/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleIOTestCase(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return s.CreateIOTestCase(w, r)
+	}
+	if r.Method == "DELETE" {
+		return s.DeleteIOTestCase(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) CreateIOTestCase(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req     models.CreateIOTestCaseRequest
+		context = r.Context()
+	)
+
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "Invalid request body")
+	}
+
+	res, err := s.ioTestCaseLogic.CreateIOTestCase(context, &req)
+	if err != nil {
+		s.logger.Error("fail to create io test case", zap.String("problemUUID", req.ProblemUUID))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, res)
+}
+
+func (s *apiServerHandler) DeleteIOTestCase(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req models.DeleteIOTestCaseRequest
+		ctx = r.Context()
+	)
+
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(ctx, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	params := mux.Vars(r)
+	uuid := params["ioTestCaseUUID"]
+	if uuid == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	req.UUID = uuid
+	err = s.ioTestCaseLogic.DeleteIOTestCase(ctx, &req)
+	if err != nil {
+		s.logger.Error("fail to delete io test case", zap.String("ioTestCaseUUID", uuid))
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully deleted io test case")
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"example/server/handlers/models"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleLanguageList(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.GetLanguageList(w, r)
+	}
+	return nil
+}
+
+// GetLanguageList is public: the frontend needs the languages before login
+// to render its editor. ?problemUUID= adds which ones the problem supports.
+func (s *apiServerHandler) GetLanguageList(w http.ResponseWriter, r *http.Request) error {
+	req := models.GetLanguageListRequest{ProblemUUID: r.URL.Query().Get("problemUUID")}
+	res, err := s.problemLogic.GetLanguages(r.Context(), &req)
+	if err != nil {
+		s.logger.Error("fail to get languages", zap.String("problemUUID", req.ProblemUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, res)
+}
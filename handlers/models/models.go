@@ -7,6 +7,7 @@ import (
 type CreateSubmissionRequest struct {
 	ProblemUUID       string
 	Content           string `validate:"min=1,max=64000"`
+	Files             []db.SubmissionFile
 	Language          string `validate:"max=32"`
 	AuthorAccountUUID string
 }
@@ -16,6 +17,8 @@ type CreateSubmissionResponse struct {
 
 type GetSubmissionRequest struct {
 	UUID string
+	// ShowHiddenTests leaves the details of hidden tests in, for admins.
+	ShowHiddenTests bool
 }
 
 type GetSubmissionListResponse struct {
@@ -28,18 +31,40 @@ type DeleteSubmissionRequest struct {
 type UpdateSubmissionRequest struct {
 	UUID          string
 	GradingResult string
-	Status        uint8 `validate:"oneof=1 2 3"`
+	Status        uint8 `validate:"oneof=1 2 3 4"`
 	Result        uint8 `validate:"oneof=1 2 3 4 5 6 7"`
 }
 
 type GetSubmissionResponse struct {
 	Submission db.Submission
+	// LogDownloadURL points admins at the full judge log, when there is one.
+	LogDownloadURL string `json:",omitempty"`
+}
+
+type GetSubmissionLogRequest struct {
+	SubmissionUUID string
+}
+
+type CancelSubmissionRequest struct {
+	SubmissionUUID string
+	AccountUUID    string
+	IsAdmin        bool
+}
+
+type CancelSubmissionResponse struct {
+	// Status is Cancelled once the submission has stopped. A running
+	// submission keeps its status until its judge notices the cancellation.
+	Status db.SubmissionStatus
 }
 
 type CreateTestCaseRequest struct {
 	ProblemUUID string
 	Content     string `validate:"max=5242880"`
 	IsHidden    bool
+	// HiddenTests and SampleTests name test methods whose visibility differs
+	// from IsHidden.
+	HiddenTests []string
+	SampleTests []string
 	Language    string
 }
 type GetTestCaseListRequest struct {
@@ -56,6 +81,58 @@ type GetTestCaseResponse struct {
 	TestCase db.TestCase
 }
 
+type CreateIOTestCaseRequest struct {
+	ProblemUUID string
+	Order       int
+	Input       string `validate:"max=5242880"`
+	Answer      string `validate:"max=5242880"`
+	IsHidden    bool
+	// Subtasks names the problem's subtasks the test counts towards.
+	Subtasks []string
+}
+
+type CreateIOTestCaseResponse struct {
+	UUID  string
+	Order int
+}
+
+type GetIOTestCaseListRequest struct {
+	ProblemUUID string
+}
+
+type GetIOTestCaseListResponse struct {
+	IOTestCaseList []db.IOTestCase
+}
+
+type DeleteIOTestCaseRequest struct {
+	UUID string
+}
+
+type CreateProblemFixtureRequest struct {
+	ProblemUUID string
+	// Name is the fixture's path relative to the work directory.
+	Name    string
+	Content string
+	// Base64 marks Content as base64 encoded, for binary files.
+	Base64 bool
+}
+
+type CreateProblemFixtureResponse struct {
+	Fixture db.ProblemFixture
+}
+
+type GetProblemFixtureListRequest struct {
+	ProblemUUID string
+}
+
+type GetProblemFixtureListResponse struct {
+	Fixtures []db.ProblemFixture
+}
+
+type DeleteProblemFixtureRequest struct {
+	UUID string
+}
+
 type GetProblemRequest struct {
 	UUID string
 }
@@ -71,12 +148,42 @@ type CreateProblemRequest struct {
 	AuthorName             string
 	TimeLimitInMillisecond uint64
 	MemoryLimitInByte      uint64
+	JudgeMode              string `validate:"omitempty,oneof=test_harness standard_io interactive"`
 }
 
 type DeleteProblemRequest struct {
 	ProblemUUID string
 }
 
+type SetProblemCheckerRequest struct {
+	ProblemUUID string
+	Language    string `validate:"max=32"`
+	Source      string `validate:"min=1,max=64000"`
+}
+
+type DeleteProblemCheckerRequest struct {
+	ProblemUUID string
+}
+
+type SetProblemInteractorRequest struct {
+	ProblemUUID string
+	Language    string `validate:"max=32"`
+	Source      string `validate:"min=1,max=64000"`
+}
+
+type DeleteProblemInteractorRequest struct {
+	ProblemUUID string
+}
+
+type SetProblemSubtasksRequest struct {
+	ProblemUUID string
+	Subtasks    []db.Subtask
+}
+
+type DeleteProblemSubtasksRequest struct {
+	ProblemUUID string
+}
+
 type CreateProblemResponse struct {
 	UUID              string
 	DisplayName       string
@@ -116,6 +223,9 @@ type CreateTestCaseAndSubmissionSnippetRequest struct {
 	CodeTest      string
 	OfProblemUUID string
 	Language      string
+	IsHidden      bool
+	HiddenTests   []string
+	SampleTests   []string
 }
 
 type GetAccountRequest struct {
@@ -167,3 +277,97 @@ type CreateSessionResponse struct {
 	Role        string
 	AccountUUID string
 }
+
+type ContainerPoolHealth struct {
+	Language        string
+	Mode            string
+	Size            int
+	Idle            int
+	InUse           int
+	Created         int64
+	HandedOut       int64
+	Missed          int64
+	Failures        int64
+	LastError       string
+	LastFailureTime int64
+	Healthy         bool
+}
+
+type GetContainerPoolHealthResponse struct {
+	Pools []*ContainerPoolHealth
+}
+
+type RunCodeRequest struct {
+	ProblemUUID string
+	Content     string `validate:"min=1,max=64000"`
+	Files       []db.SubmissionFile
+	Language    string `validate:"max=32"`
+	Stdin       string
+}
+
+type RunCodeResponse struct {
+	Result                db.SubmissionResult
+	CompileOutput         string
+	StdOut                string
+	StdErr                string
+	ExitCode              int64
+	TimeInMillisecond     int64
+	WallTimeInMillisecond int64
+	MemoryInByte          uint64
+}
+
+// RejudgeRequest targets either one submission, or every submission of a
+// problem, optionally restricted to one language.
+type RejudgeRequest struct {
+	SubmissionUUID string
+	ProblemUUID    string
+	Language       string `validate:"max=32"`
+}
+
+type RejudgeResponse struct {
+	// Submissions still waiting for their first verdict, or already queued
+	// for a rejudge, are skipped, since they will be judged against the
+	// current tests anyway.
+	QueuedSubmissionUUIDs  []string
+	SkippedSubmissionUUIDs []string
+}
+
+// RunnerReadiness is whether one sandbox of a language can run yet, for
+// instance whether its image has been pulled.
+type RunnerReadiness struct {
+	Mode        string
+	Image       string
+	State       string
+	Reason      string
+	Attempts    int
+	UpdatedTime int64
+}
+
+type LanguageReadiness struct {
+	Language string
+	Name     string
+	State    string
+	Reason   string
+	Runners  []*RunnerReadiness
+}
+
+type GetLanguageListRequest struct {
+	// ProblemUUID is optional; without it HasSnippet and HasTest are false.
+	ProblemUUID string
+}
+
+type LanguageInfo struct {
+	Value          string
+	Name           string
+	SourceFileName string
+	HasSnippet     bool
+	HasTest        bool
+}
+
+type GetLanguageListResponse struct {
+	Languages []*LanguageInfo
+}
+
+type GetLanguageReadinessResponse struct {
+	Languages []*LanguageReadiness
+}
@@ -57,6 +57,11 @@ func (s *apiServerHandler) GetProblem(w http.ResponseWriter, r *http.Request) er
 	if err != nil {
 		return WriteJSON(w, http.StatusInternalServerError, err)
 	}
+	// Setter programs are judging material, like hidden tests.
+	if role == RoleContestant && problem != nil {
+		problem.Problem.Checker = nil
+		problem.Problem.Interactor = nil
+	}
 	return WriteJSON(w, http.StatusOK, problem)
 }
 
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/server/handlers/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleProblemChecker(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "PUT" {
+		return s.SetProblemChecker(w, r)
+	}
+	if r.Method == "DELETE" {
+		return s.DeleteProblemChecker(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) SetProblemChecker(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req     models.SetProblemCheckerRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "Invalid request body")
+	}
+	req.ProblemUUID = mux.Vars(r)["problemUUID"]
+	if req.ProblemUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+
+	err = s.problemLogic.SetProblemChecker(context, &req)
+	if err != nil {
+		s.logger.Error("fail to set problem checker", zap.String("problemUUID", req.ProblemUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully set the problem checker")
+}
+
+func (s *apiServerHandler) DeleteProblemChecker(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req     models.DeleteProblemCheckerRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	req.ProblemUUID = mux.Vars(r)["problemUUID"]
+	if req.ProblemUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	err = s.problemLogic.DeleteProblemChecker(context, &req)
+	if err != nil {
+		s.logger.Error("fail to delete problem checker", zap.String("problemUUID", req.ProblemUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully deleted the problem checker")
+}
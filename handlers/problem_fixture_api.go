@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"example/server/handlers/models"
+	"example/server/logic"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleProblemFixture(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return s.CreateProblemFixture(w, r)
+	}
+	if r.Method == "DELETE" {
+		return s.DeleteProblemFixture(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) CreateProblemFixture(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req models.CreateProblemFixtureRequest
+		ctx = r.Context()
+	)
+
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(ctx, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "Invalid request body")
+	}
+
+	res, err := s.problemFixtureLogic.CreateProblemFixture(ctx, &req)
+	switch {
+	case errors.Is(err, logic.ErrFixtureTooLarge):
+		return WriteJSON(w, http.StatusRequestEntityTooLarge, err.Error())
+	case err != nil:
+		s.logger.Error("fail to create problem fixture", zap.String("problemUUID", req.ProblemUUID))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, res)
+}
+
+func (s *apiServerHandler) DeleteProblemFixture(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req models.DeleteProblemFixtureRequest
+		ctx = r.Context()
+	)
+
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(ctx, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	params := mux.Vars(r)
+	uuid := params["problemFixtureUUID"]
+	if uuid == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	req.UUID = uuid
+	err = s.problemFixtureLogic.DeleteProblemFixture(ctx, &req)
+	if err != nil {
+		s.logger.Error("fail to delete problem fixture", zap.String("problemFixtureUUID", uuid))
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully deleted problem fixture")
+}
+
+func (s *apiServerHandler) handleProblemFixtureList(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.GetProblemFixtureList(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) GetProblemFixtureList(w http.ResponseWriter, r *http.Request) error {
+	var (
+		request models.GetProblemFixtureListRequest
+		ctx     = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(ctx, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusForbidden, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusForbidden, "Insufficient permissions")
+	}
+
+	params := mux.Vars(r)
+	uuid := params["problemUUID"]
+	if uuid == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	request.ProblemUUID = uuid
+
+	res, err := s.problemFixtureLogic.GetProblemFixtureList(ctx, &request)
+	if err != nil {
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+
+	return WriteJSON(w, http.StatusOK, res)
+}
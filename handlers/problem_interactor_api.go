@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/server/handlers/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleProblemInteractor(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "PUT" {
+		return s.SetProblemInteractor(w, r)
+	}
+	if r.Method == "DELETE" {
+		return s.DeleteProblemInteractor(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) SetProblemInteractor(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req     models.SetProblemInteractorRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "Invalid request body")
+	}
+	req.ProblemUUID = mux.Vars(r)["problemUUID"]
+	if req.ProblemUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+
+	err = s.problemLogic.SetProblemInteractor(context, &req)
+	if err != nil {
+		s.logger.Error("fail to set problem interactor", zap.String("problemUUID", req.ProblemUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully set the problem interactor")
+}
+
+func (s *apiServerHandler) DeleteProblemInteractor(w http.ResponseWriter, r *http.Request) error {
+	var (
+		req     models.DeleteProblemInteractorRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	req.ProblemUUID = mux.Vars(r)["problemUUID"]
+	if req.ProblemUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	err = s.problemLogic.DeleteProblemInteractor(context, &req)
+	if err != nil {
+		s.logger.Error("fail to delete problem interactor", zap.String("problemUUID", req.ProblemUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	return WriteJSON(w, http.StatusOK, "Successfully deleted the problem interactor")
+}
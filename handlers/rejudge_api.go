@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/server/handlers/models"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleRejudge(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return s.Rejudge(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) Rejudge(w http.ResponseWriter, r *http.Request) error {
+	s.logger.Info("Request POST rejudge")
+	var (
+		req     models.RejudgeRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	username, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleAdmin:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "Invalid request body")
+	}
+	response, err := s.submissionLogic.RejudgeSubmissions(context, username, &req)
+	if err != nil {
+		s.logger.Error("fail to rejudge submissions", zap.Error(err))
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	s.logger.Info("Response POST rejudge", zap.Int("queued", len(response.QueuedSubmissionUUIDs)))
+	return WriteJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"example/server/handlers/models"
+	"example/server/logic"
+	"net/http"
+)
+
+func (s *apiServerHandler) handleRunCode(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return s.RunCode(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) RunCode(w http.ResponseWriter, r *http.Request) error {
+	s.logger.Info("Request POST run code")
+	var (
+		runCodeRequest models.RunCodeRequest
+		context        = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	username, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant, RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&runCodeRequest)
+	if err != nil {
+		s.logger.Error("fail to decode run code request body")
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
+	response, err := s.judgeLogic.RunCode(context, username, &runCodeRequest)
+	switch {
+	case errors.Is(err, logic.ErrRunCodeRateLimited):
+		return WriteJSON(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, logic.ErrRunCodeInputTooLarge), errors.Is(err, logic.ErrSubmissionTooLarge):
+		return WriteJSON(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, logic.ErrInvalidFileName), errors.Is(err, logic.ErrTooManySubmissionFiles):
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, logic.ErrRunCodeBusy), errors.Is(err, logic.ErrLanguageNotReady):
+		return WriteJSON(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		s.logger.Error("fail to run code")
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+	s.logger.Info("Response POST run code")
+	return WriteJSON(w, http.StatusOK, response)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"example/server/handlers/models"
+	"example/server/logic"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -39,10 +41,8 @@ func (s *apiServerHandler) GetSubmission(w http.ResponseWriter, r *http.Request)
 		return WriteJSON(w, http.StatusUnauthorized, err.Error())
 	}
 	switch role {
-	case RoleContestant, RoleAdmin:
+	case RoleContestant, RoleAdmin, RoleProblemSetter:
 		break
-	case RoleProblemSetter:
-		return WriteJSON(w, http.StatusUnauthorized, "Problem Setter can't get submission")
 	default:
 		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
 	}
@@ -53,10 +53,17 @@ func (s *apiServerHandler) GetSubmission(w http.ResponseWriter, r *http.Request)
 		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
 	}
 	getSubmissionRequest.UUID = uuid
+	// Setters and admins see what the judge ran; contestants only what the
+	// problem shows them.
+	canSeeJudgeDetails := role == RoleAdmin || role == RoleProblemSetter
+	getSubmissionRequest.ShowHiddenTests = canSeeJudgeDetails
 	res, err := s.submissionLogic.GetSubmission(context, &getSubmissionRequest)
 	if err != nil {
 		return WriteJSON(w, http.StatusInternalServerError, err.Error())
 	}
+	if canSeeJudgeDetails && res.Submission.LogFileID != "" {
+		res.LogDownloadURL = "/submission-log/" + uuid
+	}
 	s.logger.Info("Response GET submission")
 	return WriteJSON(w, http.StatusOK, res)
 }
@@ -91,6 +98,15 @@ func (s *apiServerHandler) CreateSubmission(w http.ResponseWriter, r *http.Reque
 	}
 	s.logger.Info("Successfully decode submission request")
 	response, err := s.submissionLogic.CreateSubmission(context, &submissionRequest)
+	if errors.Is(err, logic.ErrLanguageNotReady) {
+		return WriteJSON(w, http.StatusServiceUnavailable, err.Error())
+	}
+	if errors.Is(err, logic.ErrSubmissionTooLarge) {
+		return WriteJSON(w, http.StatusRequestEntityTooLarge, err.Error())
+	}
+	if errors.Is(err, logic.ErrInvalidFileName) || errors.Is(err, logic.ErrTooManySubmissionFiles) {
+		return WriteJSON(w, http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		s.logger.Error("fail to create submission")
 		return WriteJSON(w, http.StatusInternalServerError, err)
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"example/server/handlers/models"
+	"example/server/logic"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleSubmissionCancel(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return s.CancelSubmission(w, r)
+	}
+	return nil
+}
+
+func (s *apiServerHandler) CancelSubmission(w http.ResponseWriter, r *http.Request) error {
+	s.logger.Info("Request POST submission cancel")
+	var (
+		req     models.CancelSubmissionRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	username, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant, RoleAdmin:
+		break
+	default:
+		return WriteJSON(w, http.StatusUnauthorized, "Insufficient permissions")
+	}
+
+	req.SubmissionUUID = mux.Vars(r)["submissionUUID"]
+	if req.SubmissionUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	account, err := s.accountLogic.GetAccountByUsername(context, username)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	req.AccountUUID = account.Account.UUID
+	req.IsAdmin = role == RoleAdmin
+
+	response, err := s.submissionLogic.CancelSubmission(context, &req)
+	switch {
+	case errors.Is(err, logic.ErrSubmissionNotFound):
+		return WriteJSON(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, logic.ErrSubmissionNotOwned):
+		return WriteJSON(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, logic.ErrSubmissionNotCancellable):
+		return WriteJSON(w, http.StatusConflict, err.Error())
+	case err != nil:
+		s.logger.Error("fail to cancel submission", zap.String("submissionUUID", req.SubmissionUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+	s.logger.Info("Response POST submission cancel")
+	return WriteJSON(w, http.StatusOK, response)
+}
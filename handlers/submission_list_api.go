@@ -48,7 +48,7 @@ func (s *apiServerHandler) GetSubmissionList(w http.ResponseWriter, r *http.Requ
 		return WriteJSON(w, http.StatusBadRequest, "Missing authorAccountUUID parameter")
 	}
 
-	submissions, err = s.submissionLogic.GetSubmissionsByProblemAndAuthor(ctx, problemUUID, authorAccountUUID)
+	submissions, err = s.submissionLogic.GetSubmissionsByProblemAndAuthor(ctx, problemUUID, authorAccountUUID, role == RoleAdmin)
 
 	if err != nil {
 		return WriteJSON(w, http.StatusInternalServerError, "Failed to retrieve submissions")
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"example/server/handlers/models"
+	"example/server/logic"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func (s *apiServerHandler) handleSubmissionLog(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.GetSubmissionLog(w, r)
+	}
+	return nil
+}
+
+// GetSubmissionLog downloads the full judge log of a submission, of which
+// the submission's grading result may only keep the start.
+func (s *apiServerHandler) GetSubmissionLog(w http.ResponseWriter, r *http.Request) error {
+	s.logger.Info("Request GET submission log")
+	var (
+		req     models.GetSubmissionLogRequest
+		context = r.Context()
+	)
+	token, err := s.validateRequestAndExtractToken(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	_, role, _, err := s.tokenLogic.ExtractTokenData(context, token)
+	if err != nil {
+		return WriteJSON(w, http.StatusUnauthorized, err.Error())
+	}
+	switch role {
+	case RoleContestant:
+		return WriteJSON(w, http.StatusForbidden, "Insufficient permissions")
+	case RoleAdmin, RoleProblemSetter:
+		break
+	default:
+		return WriteJSON(w, http.StatusForbidden, "Insufficient permissions")
+	}
+
+	req.SubmissionUUID = mux.Vars(r)["submissionUUID"]
+	if req.SubmissionUUID == "" {
+		return WriteJSON(w, http.StatusBadRequest, "Missing UUID parameter")
+	}
+	log, err := s.submissionLogic.GetSubmissionLog(context, &req)
+	switch {
+	case errors.Is(err, logic.ErrSubmissionNotFound), errors.Is(err, logic.ErrSubmissionLogNotFound):
+		return WriteJSON(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		s.logger.Error("fail to open submission log", zap.String("submissionUUID", req.SubmissionUUID), zap.Error(err))
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
+	}
+	defer log.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.SubmissionUUID+".log"))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, log); err != nil {
+		// The status is already sent, so the client only sees a cut off file.
+		s.logger.Error("fail to stream submission log", zap.String("submissionUUID", req.SubmissionUUID), zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package logic
+
+import (
+	"context"
+	"example/server/configs"
+	"example/server/db"
+	"example/server/handlers/models"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+// File names a checker finds its arguments under, in testlib's order.
+const (
+	checkerInputFileName  = "input.txt"
+	checkerOutputFileName = "output.txt"
+	checkerAnswerFileName = "answer.txt"
+)
+
+// testlib exit codes that don't mean a wrong answer.
+const (
+	checkerExitCodeOK   = 0
+	checkerExitCodeFail = 3
+)
+
+// signalExitCodeBase is added to the signal number when a process is killed by one.
+const signalExitCodeBase = 128
+
+// languageChecker builds and runs setter programs, checkers or interactors,
+// written in one language.
+type languageChecker struct {
+	compile                Compile
+	run                    TestCaseRun
+	timeLimitInMillisecond uint64
+	memoryLimitInByte      uint64
+}
+
+func newLanguageChecker(docker *client.Client, logger *zap.Logger, language string, mode string, checkerConfig *configs.Checker, dependencyCacheDir string, outputLimitInByte uint64) (*languageChecker, error) {
+	timeLimit, err := checkerConfig.GetTimeLimitDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse checker time limit")
+		return nil, err
+	}
+	memoryLimitInByte, err := checkerConfig.GetMemoryInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse checker memory")
+		return nil, err
+	}
+	var compileLogic Compile
+	if checkerConfig.Compile != nil {
+		compileLogic, err = NewCompileLogic(docker, logger, language, checkerConfig.Compile, dependencyCacheDir, outputLimitInByte)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Setter programs aren't pinned, so an interactor never waits for a core
+	// held by the submission it talks to.
+	runLogic, err := NewTestCaseRunLogic(docker, logger, language, mode, &checkerConfig.Run, dependencyCacheDir, nil, outputLimitInByte)
+	if err != nil {
+		return nil, err
+	}
+	return &languageChecker{
+		compile:                compileLogic,
+		run:                    runLogic,
+		timeLimitInMillisecond: uint64(timeLimit.Milliseconds()),
+		memoryLimitInByte:      memoryLimitInByte,
+	}, nil
+}
+
+// readiness lists what running a setter program in the language needs: its
+// compiler, its run image and the library it includes.
+func (c *languageChecker) readiness() []*models.RunnerReadiness {
+	var runners []*models.RunnerReadiness
+	if c.compile != nil {
+		runners = append(runners, c.compile.Readiness())
+	}
+	return append(runners, c.run.Readiness(), c.run.LibraryReadiness())
+}
+
+// preparedChecker is a problem's checker built once for judging one
+// submission. Interpreted checkers have no program directory and run from source.
+type preparedChecker struct {
+	checker          *languageChecker
+	source           string
+	programDirectory string
+}
+
+// prepareChecker builds a setter program, the checker or the interactor,
+// with the language logic from programs. It returns nil when the problem has
+// no such program. A program that doesn't build is the setter's mistake, so
+// it fails the job rather than the submission.
+func (j judge) prepareChecker(ctx context.Context, problemUUID string, program *db.ProblemProgram, programs map[string]*languageChecker) (*preparedChecker, error) {
+	if program == nil {
+		return nil, nil
+	}
+	checker := programs[program.Language]
+	if checker == nil {
+		return nil, fmt.Errorf("%w: language %s can't run setter programs of this kind", errProblemMisconfigured, program.Language)
+	}
+	if checker.compile == nil {
+		return &preparedChecker{checker: checker, source: program.Source}, nil
+	}
+	compileOutput, err := checker.compile.Compile(ctx, program.Source)
+	if err != nil {
+		j.logger.Error("fail to compile setter program", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return nil, err
+	}
+	if compileOutput.ReturnCode != 0 {
+		os.RemoveAll(compileOutput.WorkingDir)
+		return nil, fmt.Errorf("%w: setter program of problem %s does not compile: %s", errProblemMisconfigured, problemUUID, compileOutput.StdErr)
+	}
+	return &preparedChecker{
+		checker:          checker,
+		source:           program.Source,
+		programDirectory: compileOutput.WorkingDir,
+	}, nil
+}
+
+func (c *preparedChecker) close() {
+	if c != nil && c.programDirectory != "" {
+		os.RemoveAll(c.programDirectory)
+	}
+}
+
+// check runs the checker on one test and maps its testlib exit code to a
+// test result. Only a checker that fails or crashes returns an error.
+func (c *preparedChecker) check(ctx context.Context, input string, output string, answer string) (db.TestResult, error) {
+	files := map[string]string{
+		checkerInputFileName:  input,
+		checkerOutputFileName: output,
+		checkerAnswerFileName: answer,
+	}
+	checkerOutput, err := c.checker.run.RunWithFiles(ctx, RunSource{Content: c.source}, c.programDirectory, files,
+		formatTimeLimit(c.checker.timeLimitInMillisecond), c.checker.memoryLimitInByte)
+	if err != nil {
+		return db.TestResult{}, err
+	}
+	return classifyCheckerOutput(checkerOutput)
+}
+
+func classifyCheckerOutput(output RunOutput) (db.TestResult, error) {
+	message := strings.TrimSpace(output.StdErr)
+	if message == "" {
+		message = strings.TrimSpace(output.StdOut)
+	}
+	switch {
+	case output.TimeLimitExceeded, output.MemoryLimitExceeded, output.OutputLimitExceeded:
+		return db.TestResult{}, fmt.Errorf("checker exceeded its limits")
+	case output.ExitCode == checkerExitCodeOK:
+		return db.TestResult{Result: db.SubmissionResultOK, Message: truncateTestMessage(message)}, nil
+	case output.ExitCode == checkerExitCodeFail:
+		return db.TestResult{}, fmt.Errorf("checker failed: %s", truncateTestMessage(message))
+	case output.ExitCode > signalExitCodeBase:
+		// Killed by a signal rather than exiting with a verdict.
+		return db.TestResult{}, fmt.Errorf("checker crashed with exit code %d: %s", output.ExitCode, truncateTestMessage(message))
+	default:
+		// Wrong answer, presentation error, unexpected EOF and partial
+		// points all count as wrong until partial scoring exists.
+		return db.TestResult{Result: db.SubmissionResultWrongAnswer, Message: truncateTestMessage(message)}, nil
+	}
+}
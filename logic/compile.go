@@ -3,11 +3,13 @@ package logic
 import (
 	"context"
 	"example/server/configs"
+	"example/server/handlers/models"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 	"github.com/dustin/go-humanize"
 	"github.com/google/uuid"
@@ -15,6 +17,9 @@ import (
 )
 
 type CompileOutput struct {
+	// WorkingDir is the host directory holding the source and every artefact
+	// the compiler produced. Runs copy it instead of compiling again.
+	WorkingDir      string
 	ProgramFilePath string
 	ReturnCode      int64
 	StdOut          string
@@ -23,38 +28,37 @@ type CompileOutput struct {
 
 type Compile interface {
 	Compile(ctx context.Context, content string) (CompileOutput, error)
+	// CompileFiles is Compile with extra files, such as a test file, written
+	// next to the source.
+	CompileFiles(ctx context.Context, content string, files map[string]string) (CompileOutput, error)
+	Readiness() *models.RunnerReadiness
 }
 
 type compile struct {
-	dockerClient    *client.Client
-	logger          *zap.Logger
-	language        string
-	compileConfig   *configs.Compile
-	timeoutDuration time.Duration
-	memoryInBytes   int64
+	logger            *zap.Logger
+	language          string
+	compileConfig     *configs.Compile
+	timeoutDuration   time.Duration
+	memoryInBytes     uint64
+	outputLimitInByte uint64
+	runner            Runner
 }
 
-func (c compile) pullImage() error {
-
-	_, err := c.dockerClient.ImagePull(context.Background(), c.compileConfig.Image, image.PullOptions{})
-	if err != nil {
-		c.logger.Error("failed to pull compile image" + ": " + c.compileConfig.Image)
-		return err
-	}
-	c.logger.Info("Successfully pulled compile image" + ": " + c.compileConfig.Image)
-	return nil
-}
+// compileTimeoutReturnCode marks a compilation that was killed for running past its timeout.
+const compileTimeoutReturnCode = -1
 
 func NewCompileLogic(docker *client.Client,
 	logger *zap.Logger,
 	language string,
 	compileConfig *configs.Compile,
+	dependencyCacheDir string,
+	outputLimitInByte uint64,
 ) (Compile, error) {
 	c := &compile{
-		dockerClient:  docker,
-		logger:        logger,
-		language:      language,
-		compileConfig: compileConfig,
+		logger:            logger,
+		language:          language,
+		compileConfig:     compileConfig,
+		outputLimitInByte: outputLimitInByte,
 	}
 
 	if compileConfig == nil {
@@ -64,6 +68,7 @@ func NewCompileLogic(docker *client.Client,
 	timeoutDuration, err := time.ParseDuration(compileConfig.Timeout)
 	if err != nil {
 		logger.Error("failed to parse timeout string")
+		return nil, err
 	}
 	c.timeoutDuration = timeoutDuration
 	memoryInBytes, err := humanize.ParseBytes(compileConfig.Memory)
@@ -71,18 +76,32 @@ func NewCompileLogic(docker *client.Client,
 		c.logger.With(zap.Error(err)).Error("failed to get memory in bytes")
 		return nil, err
 	}
-	c.memoryInBytes = int64(memoryInBytes)
-
-	go func() {
-		c.pullImage()
-	}()
+	c.memoryInBytes = memoryInBytes
+
+	runner, err := newRunner(docker, logger, language, "compile", compileConfig.Backend, runnerConfig{
+		Image:       compileConfig.Image,
+		WorkingDir:  c.getWorkingDir(),
+		CPUQuota:    compileConfig.CPUQuota,
+		NetworkMode: "none",
+		Mounts:      getDependencyCacheMounts(dependencyCacheDir),
+		Native:      compileConfig.Native,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.runner = runner
 
 	return c, nil
 }
 
 // Compile implements Compile.
 func (c *compile) Compile(ctx context.Context, content string) (CompileOutput, error) {
-	hostWorkingDir, err := os.MkdirTemp("", "")
+	return c.CompileFiles(ctx, content, nil)
+}
+
+// CompileFiles implements Compile.
+func (c *compile) CompileFiles(ctx context.Context, content string, files map[string]string) (CompileOutput, error) {
+	hostWorkingDir, err := makeWorkspaceDir(workspaceDirPrefix)
 	if err != nil {
 		c.logger.With(zap.Error(err)).Error("failed to create working directory for compiling")
 		return CompileOutput{}, err
@@ -96,16 +115,87 @@ func (c *compile) Compile(ctx context.Context, content string) (CompileOutput, e
 		}
 
 		return CompileOutput{
+			WorkingDir:      hostWorkingDir,
 			ProgramFilePath: sourceFile.Name(),
 		}, nil
 	}
 
-	defer func() {
-		if err = os.Remove(sourceFile.Name()); err != nil {
-			c.logger.With(zap.Error(err)).Error("failed to remove source file")
+	_, err = c.createSourceFile(ctx, hostWorkingDir, c.compileConfig.SourceFileName, content)
+	if err != nil {
+		return CompileOutput{}, err
+	}
+	for fileName, fileContent := range files {
+		if err := writeWorkspaceFile(hostWorkingDir, fileName, []byte(fileContent), 0644); err != nil {
+			c.logger.With(zap.Error(err)).Error("failed to write source file", zap.String("fileName", fileName))
+			return CompileOutput{}, err
 		}
-	}()
-	return CompileOutput{}, err
+	}
+
+	// The working directory outlives the compilation as the program
+	// directory, so it isn't acquired from and released to the runner.
+	result, err := c.runner.Run(ctx, &Workspace{HostDir: hostWorkingDir}, RunSpec{
+		Command:           c.getCompileCommand(),
+		KillAfter:         c.timeoutDuration,
+		MemoryLimitInByte: c.memoryInBytes,
+		OutputLimitInByte: c.outputLimitInByte,
+	})
+	if err != nil {
+		c.logger.With(zap.Error(err)).Error("failed to run compiler")
+		return CompileOutput{}, err
+	}
+	if result.Killed {
+		c.logger.Warn("compilation timed out", zap.String("language", c.language), zap.Duration("timeout", c.timeoutDuration))
+		return CompileOutput{
+			WorkingDir: hostWorkingDir,
+			ReturnCode: compileTimeoutReturnCode,
+			StdErr:     fmt.Sprintf("compilation exceeded the time limit of %s", c.timeoutDuration),
+		}, nil
+	}
+
+	return CompileOutput{
+		WorkingDir:      hostWorkingDir,
+		ProgramFilePath: filepath.Join(hostWorkingDir, c.compileConfig.ProgramFileName),
+		ReturnCode:      result.ExitCode,
+		StdOut:          result.StdOut,
+		StdErr:          result.StdErr,
+	}, nil
+}
+
+// Readiness reports whether the compiler image is available, or nil for
+// languages without a compile step.
+func (c *compile) Readiness() *models.RunnerReadiness {
+	if c.runner == nil {
+		return nil
+	}
+	return c.runner.Readiness()
+}
+
+// getDependencyCacheMounts exposes the dependency cache read-only, so headers
+// such as testlib.h can be included without network access.
+func getDependencyCacheMounts(dependencyCacheDir string) []runnerMount {
+	if dependencyCacheDir == "" {
+		return nil
+	}
+	return []runnerMount{{HostPath: dependencyCacheDir, Path: testLibraryMountPath, ReadOnly: true}}
+}
+
+func (c compile) getWorkingDir() string {
+	if c.compileConfig.WorkingDir != "" {
+		return c.compileConfig.WorkingDir
+	}
+	return "/work"
+}
+
+func (c compile) getCompileCommand() []string {
+	replacer := strings.NewReplacer(
+		"$SOURCE", c.compileConfig.SourceFileName,
+		"$PROGRAM", c.compileConfig.ProgramFileName,
+	)
+	command := make([]string, len(c.compileConfig.CommandTemplate))
+	for i := range c.compileConfig.CommandTemplate {
+		command[i] = replacer.Replace(c.compileConfig.CommandTemplate[i])
+	}
+	return command
 }
 
 func (c compile) createSourceFile(
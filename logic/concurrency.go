@@ -0,0 +1,161 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cpuSetPool hands out dedicated cores so that concurrent runs never share a
+// CPU and their timings stay comparable. A nil pool disables pinning.
+type cpuSetPool struct {
+	cpus chan string
+}
+
+func newCPUSetPool(cpus []string) *cpuSetPool {
+	if len(cpus) == 0 {
+		return nil
+	}
+	p := &cpuSetPool{cpus: make(chan string, len(cpus))}
+	for _, cpu := range cpus {
+		p.cpus <- cpu
+	}
+	return p
+}
+
+// acquire blocks until a core is free. It returns "" when pinning is disabled.
+func (p *cpuSetPool) acquire(ctx context.Context) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	select {
+	case cpu := <-p.cpus:
+		return cpu, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (p *cpuSetPool) release(cpu string) {
+	if p == nil || cpu == "" {
+		return
+	}
+	p.cpus <- cpu
+}
+
+// languageLimiter tracks how many submissions of each language are being
+// judged, against the per-language caps from the config.
+type languageLimiter struct {
+	mutex   sync.Mutex
+	limits  map[string]int
+	running map[string]int
+}
+
+func newLanguageLimiter(limits map[string]int) *languageLimiter {
+	return &languageLimiter{limits: limits, running: make(map[string]int)}
+}
+
+// saturated lists the languages that are at their cap, so the queue can skip them.
+func (l *languageLimiter) saturated() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var languages []string
+	for language, limit := range l.limits {
+		if l.running[language] >= limit {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// tryAcquire takes a slot for language; it fails when another worker filled
+// the last slot between the queue claim and this call.
+func (l *languageLimiter) tryAcquire(language string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if limit, ok := l.limits[language]; ok && l.running[language] >= limit {
+		return false
+	}
+	l.running[language]++
+	return true
+}
+
+func (l *languageLimiter) release(language string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.running[language] > 0 {
+		l.running[language]--
+	}
+}
+
+// rateLimiter allows each key at most limit events within a sliding window.
+// A nil rateLimiter allows everything.
+type rateLimiter struct {
+	mutex  sync.Mutex
+	limit  int
+	window time.Duration
+	events map[string][]time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &rateLimiter{limit: limit, window: window, events: make(map[string][]time.Time)}
+}
+
+// allow records an event for key unless it already used up its window.
+func (r *rateLimiter) allow(key string) bool {
+	if r == nil {
+		return true
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	recent := r.events[key][:0]
+	for _, event := range r.events[key] {
+		if now.Sub(event) < r.window {
+			recent = append(recent, event)
+		}
+	}
+	if len(recent) >= r.limit {
+		r.events[key] = recent
+		return false
+	}
+	r.events[key] = append(recent, now)
+	return true
+}
+
+// runningJobs lets a cancellation reach the submissions this process is
+// judging right now, without waiting for the next lease extension.
+type runningJobs struct {
+	mutex   sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newRunningJobs() *runningJobs {
+	return &runningJobs{cancels: make(map[string]context.CancelFunc)}
+}
+
+func (r *runningJobs) start(submissionUUID string, cancel context.CancelFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cancels[submissionUUID] = cancel
+}
+
+func (r *runningJobs) finish(submissionUUID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.cancels, submissionUUID)
+}
+
+// cancel stops the submission if it is being judged here.
+func (r *runningJobs) cancel(submissionUUID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cancel, ok := r.cancels[submissionUUID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
@@ -0,0 +1,207 @@
+package logic
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"example/server/handlers/models"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+// poolRefillBackoff is how long the pool waits before creating containers
+// again after a failure, for example while the image is still being pulled.
+const poolRefillBackoff = 5 * time.Second
+
+// pooledContainer is an idle, already started container with its own host
+// directory bind mounted as the work directory.
+type pooledContainer struct {
+	ID             string
+	HostWorkingDir string
+}
+
+// containerPool keeps containers for one run configuration created and
+// started ahead of time. Each container serves a single run and is then
+// removed, so nothing a submission leaves behind reaches the next one; the
+// saving is that creating and starting happen off the judging path.
+type containerPool struct {
+	dockerClient *client.Client
+	logger       *zap.Logger
+	language     string
+	mode         string
+	size         int
+	create       func(ctx context.Context) (pooledContainer, error)
+
+	idle    chan pooledContainer
+	refill  chan struct{}
+	stop    context.CancelFunc
+	stopped chan struct{}
+
+	mutex      sync.Mutex
+	inUse      int
+	created    int64
+	handedOut  int64
+	missed     int64
+	failures   int64
+	lastError  string
+	lastFailAt time.Time
+}
+
+func newContainerPool(
+	dockerClient *client.Client,
+	logger *zap.Logger,
+	language string,
+	mode string,
+	size int,
+	create func(ctx context.Context) (pooledContainer, error),
+) *containerPool {
+	ctx, stop := context.WithCancel(context.Background())
+	p := &containerPool{
+		dockerClient: dockerClient,
+		logger:       logger,
+		language:     language,
+		mode:         mode,
+		size:         size,
+		create:       create,
+		idle:         make(chan pooledContainer, size),
+		refill:       make(chan struct{}, 1),
+		stop:         stop,
+		stopped:      make(chan struct{}),
+	}
+	go p.maintain(ctx)
+	return p
+}
+
+// take hands out an idle container, or nil when none is ready; the caller
+// then falls back to creating a container on the spot.
+func (p *containerPool) take() *pooledContainer {
+	if p == nil {
+		return nil
+	}
+	defer p.requestRefill()
+	select {
+	case pooled := <-p.idle:
+		p.mutex.Lock()
+		p.inUse++
+		p.handedOut++
+		p.mutex.Unlock()
+		return &pooled
+	default:
+		p.mutex.Lock()
+		p.missed++
+		p.mutex.Unlock()
+		return nil
+	}
+}
+
+// recycle removes a container that has served its run.
+func (p *containerPool) recycle(pooled *pooledContainer) {
+	if pooled == nil {
+		return
+	}
+	p.mutex.Lock()
+	p.inUse--
+	p.mutex.Unlock()
+	p.remove(context.Background(), *pooled)
+}
+
+func (p *containerPool) requestRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+func (p *containerPool) maintain(ctx context.Context) {
+	defer close(p.stopped)
+	for {
+		for len(p.idle) < p.size && ctx.Err() == nil {
+			pooled, err := p.create(ctx)
+			if err != nil {
+				p.recordFailure(err)
+				break
+			}
+			p.mutex.Lock()
+			p.created++
+			p.mutex.Unlock()
+			p.idle <- pooled
+		}
+
+		var retry <-chan time.Time
+		if len(p.idle) < p.size {
+			retry = time.After(poolRefillBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.refill:
+		case <-retry:
+		}
+	}
+}
+
+func (p *containerPool) recordFailure(err error) {
+	p.logger.Warn("fail to create pooled container",
+		zap.String("language", p.language),
+		zap.String("mode", p.mode),
+		zap.Error(err))
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures++
+	p.lastError = err.Error()
+	p.lastFailAt = time.Now()
+}
+
+func (p *containerPool) remove(ctx context.Context, pooled pooledContainer) {
+	err := p.dockerClient.ContainerRemove(ctx, pooled.ID, container.RemoveOptions{Force: true})
+	if err != nil {
+		p.logger.With(zap.Error(err)).Error("failed to remove pooled container")
+	}
+	os.RemoveAll(pooled.HostWorkingDir)
+}
+
+// close stops refilling and removes the idle containers.
+func (p *containerPool) close(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	p.stop()
+	<-p.stopped
+	for {
+		select {
+		case pooled := <-p.idle:
+			p.remove(ctx, pooled)
+		default:
+			return
+		}
+	}
+}
+
+func (p *containerPool) health() *models.ContainerPoolHealth {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	health := &models.ContainerPoolHealth{
+		Language:  p.language,
+		Mode:      p.mode,
+		Size:      p.size,
+		Idle:      len(p.idle),
+		InUse:     p.inUse,
+		Created:   p.created,
+		HandedOut: p.handedOut,
+		Missed:    p.missed,
+		Failures:  p.failures,
+		LastError: p.lastError,
+	}
+	health.Healthy = health.Idle > 0 || p.lastFailAt.IsZero() || time.Since(p.lastFailAt) > 2*poolRefillBackoff
+	if !p.lastFailAt.IsZero() {
+		health.LastFailureTime = p.lastFailAt.UnixMilli()
+	}
+	return health
+}
@@ -0,0 +1,577 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"example/server/handlers/models"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+	"go.uber.org/zap"
+)
+
+// dockerRunner runs each command in a Docker container, optionally taken
+// from a pool of containers started ahead of time.
+type dockerRunner struct {
+	dockerClient *client.Client
+	logger       *zap.Logger
+	language     string
+	config       runnerConfig
+	limits       sandboxLimits
+	// seccompProfile holds the JSON content of the configured profile; the
+	// Docker API takes the profile itself rather than a path.
+	seccompProfile string
+	pool           *containerPool
+	readiness      *readiness
+	stopPulling    context.CancelFunc
+}
+
+// Pulls that fail are retried with exponential backoff between these bounds.
+const (
+	imagePullInitialBackoff = 5 * time.Second
+	imagePullMaxBackoff     = 5 * time.Minute
+)
+
+func newDockerRunner(docker *client.Client, logger *zap.Logger, language string, mode string, config runnerConfig) (*dockerRunner, error) {
+	limits, err := parseSandboxLimits(config.Sandbox)
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to load sandbox profile")
+		return nil, err
+	}
+	d := &dockerRunner{
+		dockerClient: docker,
+		logger:       logger,
+		language:     language,
+		config:       config,
+		limits:       limits,
+		readiness:    newReadiness(mode, config.Image),
+	}
+	if config.Sandbox.SeccompProfile != "" {
+		profile, err := os.ReadFile(config.Sandbox.SeccompProfile)
+		if err != nil {
+			logger.With(zap.Error(err)).Error("failed to read seccomp profile")
+			return nil, err
+		}
+		d.seccompProfile = string(profile)
+	}
+	pullCtx, stopPulling := context.WithCancel(context.Background())
+	d.stopPulling = stopPulling
+	go d.ensureImage(pullCtx)
+	if config.PoolSize > 0 {
+		d.pool = newContainerPool(docker, logger, language, mode, config.PoolSize, d.createPooledContainer)
+	}
+	return d, nil
+}
+
+func (d *dockerRunner) Acquire(ctx context.Context) (*Workspace, error) {
+	if pooled := d.pool.take(); pooled != nil {
+		return &Workspace{HostDir: pooled.HostWorkingDir, pooled: pooled}, nil
+	}
+	hostDir, err := createWorkspaceDir(workspaceDirPrefix, d.config.Sandbox)
+	if err != nil {
+		d.logger.Error("fail to make temp directory", zap.Error(err))
+		return nil, err
+	}
+	return &Workspace{HostDir: hostDir}, nil
+}
+
+func (d *dockerRunner) Release(workspace *Workspace) {
+	if workspace == nil {
+		return
+	}
+	d.pool.recycle(workspace.pooled)
+	os.RemoveAll(workspace.HostDir)
+}
+
+func (d *dockerRunner) PoolHealth() *models.ContainerPoolHealth {
+	return d.pool.health()
+}
+
+func (d *dockerRunner) Readiness() *models.RunnerReadiness {
+	return d.readiness.snapshot()
+}
+
+func (d *dockerRunner) Close(ctx context.Context) {
+	d.stopPulling()
+	d.pool.close(ctx)
+}
+
+func (d *dockerRunner) Run(ctx context.Context, workspace *Workspace, spec RunSpec) (RunResult, error) {
+	if workspace.pooled != nil {
+		return d.execInPooledContainer(ctx, *workspace.pooled, spec)
+	}
+	return d.runFreshContainer(ctx, workspace.HostDir, spec)
+}
+
+// runFreshContainer creates a container for this run alone and removes it afterwards.
+func (d *dockerRunner) runFreshContainer(ctx context.Context, hostWorkingDir string, spec RunSpec) (RunResult, error) {
+	resp, err := d.createContainer(ctx, hostWorkingDir, spec.Command, spec.CPUSet, spec.Stdin != nil, spec.MemoryLimitInByte)
+	if err != nil {
+		d.logger.Error("fail to create Container", zap.Any("err", err))
+		return RunResult{}, err
+	}
+
+	// A cancelled run still has to take its container down with it.
+	defer func() {
+		err = d.dockerClient.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
+		if err != nil {
+			d.logger.With(zap.Error(err)).Error("failed to remove run container")
+		}
+	}()
+
+	// Output is read from the attach stream as the program runs, so a run
+	// that writes too much is stopped as soon as it passes the limit.
+	hijacked, err := d.dockerClient.ContainerAttach(ctx, resp.ID, container.AttachOptions{
+		Stream: true,
+		Stdin:  spec.Stdin != nil,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		d.logger.Error("fail to attach to the container", zap.Any("containerID", resp.ID), zap.Error(err))
+		return RunResult{}, err
+	}
+	defer hijacked.Close()
+	if err := d.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		d.logger.Error("fail to start the container", zap.Any("containerID", resp.ID))
+		return RunResult{}, err
+	}
+	if spec.Stdin != nil {
+		go func() {
+			_, err := io.Copy(hijacked.Conn, spec.Stdin)
+			if err != nil {
+				d.logger.Warn("fail to write container stdin", zap.Error(err))
+			}
+			hijacked.CloseWrite()
+		}()
+	}
+	stdoutBuf, stderrBuf, stdout := d.newOutputBuffers(ctx, resp.ID, spec)
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		stdcopy.StdCopy(stdout, stderrBuf, hijacked.Reader)
+	}()
+
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	defer stopMonitor()
+	usageCh := d.monitorResourceUsage(monitorCtx, resp.ID)
+
+	status, killed, err := d.waitContainer(ctx, resp.ID, spec.KillAfter)
+	stopMonitor()
+	usage := <-usageCh
+	if err != nil {
+		return RunResult{StdErr: "container channel response with error"}, err
+	}
+
+	oomKilled := false
+	inspect, err := d.dockerClient.ContainerInspect(ctx, resp.ID)
+	if err != nil {
+		d.logger.Warn("fail to inspect container", zap.String("containerID", resp.ID), zap.Error(err))
+	} else if inspect.ContainerJSONBase != nil && inspect.State != nil {
+		oomKilled = inspect.State.OOMKilled
+		usage.WallTime = containerWallTime(inspect.State.StartedAt, inspect.State.FinishedAt)
+	}
+
+	<-outputDone
+	return RunResult{
+		ExitCode:            status.StatusCode,
+		StdOut:              stdoutBuf.String(),
+		StdErr:              stderrBuf.String(),
+		Usage:               usage,
+		OOMKilled:           oomKilled,
+		Killed:              killed,
+		OutputLimitExceeded: stdoutBuf.exceeded || stderrBuf.exceeded,
+		Reports:             d.copyReports(ctx, resp.ID, spec),
+	}, nil
+}
+
+// copyReports copies the reports directory out of the container, which has
+// to still exist. Reports only add detail to a run, so failing to copy them
+// is logged rather than failing the run.
+func (d *dockerRunner) copyReports(ctx context.Context, containerID string, spec RunSpec) map[string][]byte {
+	if spec.ReportsDir == "" {
+		return nil
+	}
+	archive, _, err := d.dockerClient.CopyFromContainer(ctx, containerID, path.Join(d.config.WorkingDir, spec.ReportsDir))
+	if err != nil {
+		if !errdefs.IsNotFound(err) {
+			d.logger.Warn("fail to copy reports from container", zap.String("containerID", containerID), zap.Error(err))
+		}
+		return nil
+	}
+	defer archive.Close()
+	reports, err := readReportsArchive(archive, spec.OutputLimitInByte)
+	if err != nil {
+		d.logger.Warn("fail to read reports from container", zap.String("containerID", containerID), zap.Error(err))
+	}
+	return reports
+}
+
+// newOutputBuffers returns the buffers a run's output is kept in, killing the
+// container once either passes the limit, and the writer stdout goes to. A
+// stdout streamed to spec.Stdout isn't kept, so its buffer stays empty.
+func (d *dockerRunner) newOutputBuffers(ctx context.Context, containerID string, spec RunSpec) (*outputBuffer, *outputBuffer, io.Writer) {
+	var killOnce sync.Once
+	exceeded := func() {
+		killOnce.Do(func() {
+			d.logger.Warn("run exceeded its output limit, killing container", zap.String("containerID", containerID))
+			go func() {
+				if err := d.dockerClient.ContainerKill(context.WithoutCancel(ctx), containerID, "KILL"); err != nil {
+					d.logger.Error("fail to kill container", zap.String("containerID", containerID), zap.Error(err))
+				}
+			}()
+		})
+	}
+	stdoutBuf := newOutputBuffer(spec.OutputLimitInByte, exceeded)
+	stderrBuf := newOutputBuffer(spec.OutputLimitInByte, exceeded)
+	var stdout io.Writer = stdoutBuf
+	if spec.Stdout != nil {
+		stdout = &lenientWriter{writer: spec.Stdout}
+	}
+	return stdoutBuf, stderrBuf, stdout
+}
+
+// execInPooledContainer runs the command inside an idle pooled container,
+// after applying this run's memory limit and core.
+func (d *dockerRunner) execInPooledContainer(ctx context.Context, pooled pooledContainer, spec RunSpec) (RunResult, error) {
+	_, err := d.dockerClient.ContainerUpdate(ctx, pooled.ID, container.UpdateConfig{Resources: container.Resources{
+		CpusetCpus: spec.CPUSet,
+		Memory:     int64(spec.MemoryLimitInByte),
+		MemorySwap: int64(spec.MemoryLimitInByte),
+	}})
+	if err != nil {
+		d.logger.Error("fail to apply limits to pooled container", zap.String("containerID", pooled.ID), zap.Error(err))
+		return RunResult{}, err
+	}
+
+	exec, err := d.dockerClient.ContainerExecCreate(ctx, pooled.ID, types.ExecConfig{
+		Cmd:          spec.Command,
+		WorkingDir:   d.config.WorkingDir,
+		AttachStdin:  spec.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		d.logger.Error("fail to create exec in pooled container", zap.String("containerID", pooled.ID), zap.Error(err))
+		return RunResult{}, err
+	}
+
+	// CPU time is read from the container's cumulative counter before and
+	// after, since the idle process has used a little already.
+	cpuTimeBefore := d.containerCPUTime(ctx, pooled.ID)
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	defer stopMonitor()
+	usageCh := d.monitorResourceUsage(monitorCtx, pooled.ID)
+
+	startedAt := time.Now()
+	hijacked, err := d.dockerClient.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		d.logger.Error("fail to start exec in pooled container", zap.String("containerID", pooled.ID), zap.Error(err))
+		return RunResult{}, err
+	}
+	defer hijacked.Close()
+	if spec.Stdin != nil {
+		go func() {
+			_, err := io.Copy(hijacked.Conn, spec.Stdin)
+			if err != nil {
+				d.logger.Warn("fail to write exec stdin", zap.Error(err))
+			}
+			hijacked.CloseWrite()
+		}()
+	}
+
+	stdoutBuf, stderrBuf, stdout := d.newOutputBuffers(ctx, pooled.ID, spec)
+	outputDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderrBuf, hijacked.Reader)
+		outputDone <- err
+	}()
+
+	var deadline <-chan time.Time
+	if spec.KillAfter > 0 {
+		timer := time.NewTimer(spec.KillAfter)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	killed := false
+	select {
+	case err := <-outputDone:
+		if err != nil {
+			d.logger.Error("Failed to copy exec output", zap.Error(err))
+			return RunResult{}, fmt.Errorf("failed to copy exec output: %w", err)
+		}
+	case <-deadline:
+		d.logger.Warn("run exceeded its wall time limit, killing pooled container", zap.String("containerID", pooled.ID))
+		killed = true
+		if err := d.dockerClient.ContainerKill(ctx, pooled.ID, "KILL"); err != nil {
+			d.logger.Error("fail to kill container", zap.String("containerID", pooled.ID), zap.Error(err))
+			return RunResult{}, err
+		}
+		<-outputDone
+	case <-ctx.Done():
+		if err := d.dockerClient.ContainerKill(context.WithoutCancel(ctx), pooled.ID, "KILL"); err != nil {
+			d.logger.Error("fail to kill cancelled run", zap.String("containerID", pooled.ID), zap.Error(err))
+		}
+		return RunResult{}, ctx.Err()
+	}
+	wallTime := time.Since(startedAt)
+	stopMonitor()
+	usage := <-usageCh
+	usage.WallTime = wallTime
+	if cpuTimeAfter := d.containerCPUTime(ctx, pooled.ID); cpuTimeAfter > cpuTimeBefore {
+		usage.CPUTime = cpuTimeAfter - cpuTimeBefore
+	}
+
+	result := RunResult{
+		StdOut:              stdoutBuf.String(),
+		StdErr:              stderrBuf.String(),
+		Usage:               usage,
+		Killed:              killed,
+		OutputLimitExceeded: stdoutBuf.exceeded || stderrBuf.exceeded,
+		Reports:             d.copyReports(ctx, pooled.ID, spec),
+	}
+	if killed {
+		result.ExitCode = sigkillExitCode
+		return result, nil
+	}
+	inspect, err := d.dockerClient.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		d.logger.Error("fail to inspect exec", zap.String("execID", exec.ID), zap.Error(err))
+		return RunResult{}, err
+	}
+	result.ExitCode = int64(inspect.ExitCode)
+	// The OOM killer only flags the container when its init process dies, so
+	// for an exec a SIGKILL at the memory ceiling is taken as running out of memory.
+	result.OOMKilled = result.ExitCode == sigkillExitCode &&
+		spec.MemoryLimitInByte > 0 &&
+		usage.PeakMemoryInByte >= spec.MemoryLimitInByte/10*9
+	return result, nil
+}
+
+func (d *dockerRunner) containerCPUTime(ctx context.Context, containerID string) time.Duration {
+	stats, err := d.dockerClient.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		d.logger.Warn("fail to read container stats", zap.String("containerID", containerID), zap.Error(err))
+		return 0
+	}
+	defer stats.Body.Close()
+	var sample types.StatsJSON
+	if err := json.NewDecoder(stats.Body).Decode(&sample); err != nil {
+		d.logger.Warn("fail to decode container stats", zap.String("containerID", containerID), zap.Error(err))
+		return 0
+	}
+	return time.Duration(sample.CPUStats.CPUUsage.TotalUsage)
+}
+
+// createPooledContainer creates and starts an idle container that waits for
+// a run to be executed in it.
+func (d *dockerRunner) createPooledContainer(ctx context.Context) (pooledContainer, error) {
+	hostWorkingDir, err := createWorkspaceDir(pooledWorkspaceDirPrefix, d.config.Sandbox)
+	if err != nil {
+		return pooledContainer{}, err
+	}
+	resp, err := d.createContainer(ctx, hostWorkingDir, d.getIdleCommand(), "", false, 0)
+	if err != nil {
+		os.RemoveAll(hostWorkingDir)
+		return pooledContainer{}, err
+	}
+	if err := d.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		d.dockerClient.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+		os.RemoveAll(hostWorkingDir)
+		return pooledContainer{}, err
+	}
+	return pooledContainer{ID: resp.ID, HostWorkingDir: hostWorkingDir}, nil
+}
+
+func (d *dockerRunner) getIdleCommand() []string {
+	if len(d.config.IdleCommand) > 0 {
+		return d.config.IdleCommand
+	}
+	return []string{"sleep", "infinity"}
+}
+
+// waitContainer waits for the container to exit, killing it once killAfter
+// has passed.
+func (d *dockerRunner) waitContainer(ctx context.Context, containerID string, killAfter time.Duration) (container.WaitResponse, bool, error) {
+	waitCtx := ctx
+	if killAfter > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, killAfter)
+		defer cancel()
+	}
+
+	statusCh, errCh := d.dockerClient.ContainerWait(waitCtx, containerID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		return status, false, nil
+	case err := <-errCh:
+		if ctx.Err() != nil {
+			if err := d.dockerClient.ContainerKill(context.WithoutCancel(ctx), containerID, "KILL"); err != nil {
+				d.logger.Error("fail to kill cancelled run", zap.String("containerID", containerID), zap.Error(err))
+			}
+			return container.WaitResponse{}, false, ctx.Err()
+		}
+		if !errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return container.WaitResponse{}, false, err
+		}
+	}
+
+	d.logger.Warn("run exceeded its wall time limit, killing container", zap.String("containerID", containerID))
+	if err := d.dockerClient.ContainerKill(ctx, containerID, "KILL"); err != nil {
+		d.logger.Error("fail to kill container", zap.String("containerID", containerID), zap.Error(err))
+		return container.WaitResponse{}, true, err
+	}
+	statusCh, errCh = d.dockerClient.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		return status, true, nil
+	case err := <-errCh:
+		return container.WaitResponse{}, true, err
+	}
+}
+
+func (d *dockerRunner) createContainer(
+	ctx context.Context,
+	hostWorkingDir string,
+	command []string,
+	cpuSet string,
+	openStdin bool,
+	memoryLimitInByte uint64,
+) (container.CreateResponse, error) {
+	d.logger.Info("HostWorkingDir: " + hostWorkingDir)
+	hostConfig := &container.HostConfig{
+		Binds: d.getBinds(hostWorkingDir),
+		Resources: container.Resources{
+			CPUQuota:   d.config.CPUQuota,
+			CpusetCpus: cpuSet,
+			Memory:     int64(memoryLimitInByte), /// 256 * 1024 * 1024 = 256 MB
+			// Equal to Memory so a submission can't dodge the limit by swapping.
+			MemorySwap: int64(memoryLimitInByte)},
+		NetworkMode: d.getNetworkMode()}
+	d.applySandbox(hostConfig)
+	resp, err := d.dockerClient.ContainerCreate(ctx, &container.Config{
+		Image:       d.config.Image,
+		Cmd:         command,
+		WorkingDir:  d.config.WorkingDir,
+		User:        d.config.Sandbox.User,
+		OpenStdin:   openStdin,
+		StdinOnce:   openStdin,
+		AttachStdin: openStdin,
+		Labels:      judgeContainerLabels(),
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return container.CreateResponse{}, err
+	}
+	return resp, nil
+}
+
+// applySandbox adds the language's hardening options to the run container.
+func (d *dockerRunner) applySandbox(hostConfig *container.HostConfig) {
+	sandbox := d.config.Sandbox
+	hostConfig.ReadonlyRootfs = sandbox.ReadOnlyRootFS
+	if d.limits.tmpfsSizeInByte > 0 {
+		hostConfig.Tmpfs = map[string]string{
+			sandbox.GetTmpfsDir(): fmt.Sprintf("rw,nosuid,nodev,size=%d", d.limits.tmpfsSizeInByte),
+		}
+	}
+	if sandbox.PidsLimit > 0 {
+		pidsLimit := sandbox.PidsLimit
+		hostConfig.Resources.PidsLimit = &pidsLimit
+	}
+	if sandbox.DropAllCapabilities {
+		hostConfig.CapDrop = []string{"ALL"}
+	}
+	if sandbox.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+	if d.seccompProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+d.seccompProfile)
+	}
+	if sandbox.OpenFilesLimit > 0 {
+		hostConfig.Resources.Ulimits = append(hostConfig.Resources.Ulimits,
+			&units.Ulimit{Name: "nofile", Soft: sandbox.OpenFilesLimit, Hard: sandbox.OpenFilesLimit})
+	}
+	if d.limits.fileSizeLimitInByte > 0 {
+		hostConfig.Resources.Ulimits = append(hostConfig.Resources.Ulimits,
+			&units.Ulimit{Name: "fsize", Soft: d.limits.fileSizeLimitInByte, Hard: d.limits.fileSizeLimitInByte})
+	}
+}
+
+func (d *dockerRunner) getBinds(hostWorkingDir string) []string {
+	binds := []string{fmt.Sprintf("%s:%s", hostWorkingDir, d.config.WorkingDir)}
+	for _, mount := range d.config.Mounts {
+		bind := fmt.Sprintf("%s:%s", mount.HostPath, mount.Path)
+		if mount.ReadOnly {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
+
+// getNetworkMode keeps untrusted code off the network unless the language
+// explicitly opts in.
+func (d *dockerRunner) getNetworkMode() container.NetworkMode {
+	if d.config.NetworkMode == "" {
+		return "none"
+	}
+	return container.NetworkMode(d.config.NetworkMode)
+}
+
+// ensureImage makes the image available, retrying failed pulls until one
+// succeeds or ctx is done. An image that is already present counts as ready
+// without a pull, so images built locally work too.
+func (d *dockerRunner) ensureImage(ctx context.Context) {
+	if _, _, err := d.dockerClient.ImageInspectWithRaw(ctx, d.config.Image); err == nil {
+		d.readiness.set(readinessReady, "")
+		return
+	}
+	backoff := imagePullInitialBackoff
+	for {
+		d.readiness.set(readinessPulling, "")
+		err := d.pullImage(ctx)
+		if err == nil {
+			d.readiness.set(readinessReady, "")
+			return
+		}
+		d.readiness.set(readinessFailed, err.Error())
+		d.logger.Warn("retrying image pull", zap.String("image", d.config.Image), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, imagePullMaxBackoff)
+	}
+}
+
+func (d *dockerRunner) pullImage(ctx context.Context) error {
+	d.logger.Info("pulling image", zap.String("image", d.config.Image))
+	reader, err := d.dockerClient.ImagePull(ctx, d.config.Image, image.PullOptions{})
+	if err != nil {
+		d.logger.With(zap.Error(err)).Error("failed to pull image", zap.String("image", d.config.Image))
+		return err
+	}
+	defer reader.Close()
+	// The pull only completes once its progress stream has been read.
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		d.logger.With(zap.Error(err)).Error("failed to pull image", zap.String("image", d.config.Image))
+		return err
+	}
+
+	d.logger.Info("pulled image successfully", zap.String("image", d.config.Image))
+	return nil
+}
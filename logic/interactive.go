@@ -0,0 +1,129 @@
+package logic
+
+import (
+	"context"
+	"example/server/db"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// judgeInteractiveSubmission runs the submission against the setter's
+// interactor once per input, with each program's stdout connected to the
+// other's stdin. The interactor reads the input (and answer) from files and
+// decides the verdict.
+func (j judge) judgeInteractiveSubmission(ctx context.Context, submission *db.Submission, problem *db.Problem, source RunSource, programDirectory string) (judgeVerdict, error) {
+	runLogic := j.languageToStandardIORunLogic[submission.Language]
+	if runLogic == nil {
+		return judgeVerdict{
+			Result:        db.SubmissionResultUnsupportedLanguage,
+			GradingResult: fmt.Sprintf("language %s does not support interactive judging", submission.Language),
+		}, nil
+	}
+	if problem.Interactor == nil {
+		return judgeVerdict{}, fmt.Errorf("%w: interactive problem %s has no interactor", errProblemMisconfigured, problem.UUID)
+	}
+	ioTestCases, err := j.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, problem.UUID)
+	if err != nil {
+		return judgeVerdict{}, err
+	}
+	if len(ioTestCases) == 0 {
+		return judgeVerdict{}, fmt.Errorf("%w: problem %s has no input/answer pairs", errProblemMisconfigured, problem.UUID)
+	}
+	interactor, err := j.prepareChecker(ctx, problem.UUID, problem.Interactor, j.languageToInteractor)
+	if err != nil {
+		return judgeVerdict{}, err
+	}
+	defer interactor.close()
+
+	verdict := judgeVerdict{Result: db.SubmissionResultOK, Log: j.newJudgeLog()}
+	for i, ioTestCase := range ioTestCases {
+		output, interactorOutput, err := j.interact(ctx, runLogic, source, programDirectory, problem, interactor, ioTestCase)
+		if err != nil {
+			return judgeVerdict{}, err
+		}
+		testResult, err := classifyInteraction(output, interactorOutput)
+		if err != nil {
+			j.logger.Error("interactor failed", zap.String("problemUUID", problem.UUID), zap.Int("test", i+1), zap.Error(err))
+			return judgeVerdict{}, err
+		}
+		testResult.Name = fmt.Sprintf("Test #%d", i+1)
+		testResult.Hidden = ioTestCase.IsHidden
+		testResult.Subtasks = ioTestCase.Subtasks
+		verdict.addTestResult(testResult, output)
+	}
+	return verdict, nil
+}
+
+// interact runs the submission and the interactor side by side. Once one of
+// them exits, the other sees end of file on its stdin and its writes are
+// dropped, so neither can hang on the other past its own time limit.
+func (j judge) interact(
+	ctx context.Context,
+	runLogic TestCaseRun,
+	source RunSource,
+	programDirectory string,
+	problem *db.Problem,
+	interactor *preparedChecker,
+	ioTestCase db.IOTestCase,
+) (RunOutput, RunOutput, error) {
+	toInteractorReader, toInteractorWriter := io.Pipe()
+	toSubmissionReader, toSubmissionWriter := io.Pipe()
+	files := map[string]string{
+		checkerInputFileName:  ioTestCase.Input,
+		checkerAnswerFileName: ioTestCase.Answer,
+	}
+	// The interactor spends most of its time waiting for the submission, so
+	// it gets its own limit on top of the problem's.
+	interactorTimeLimit := interactor.checker.timeLimitInMillisecond + problem.TimeLimitInMillisecond
+
+	var (
+		wg                                 sync.WaitGroup
+		submissionOutput, interactorOutput RunOutput
+		submissionErr, interactorErr       error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		submissionOutput, submissionErr = runLogic.RunInteractive(ctx, source, programDirectory, nil,
+			toSubmissionReader, toInteractorWriter, formatTimeLimit(problem.TimeLimitInMillisecond), problem.MemoryLimitInByte)
+		toInteractorWriter.Close()
+		toSubmissionReader.CloseWithError(io.ErrClosedPipe)
+	}()
+	go func() {
+		defer wg.Done()
+		interactorOutput, interactorErr = interactor.checker.run.RunInteractive(ctx, RunSource{Content: interactor.source}, interactor.programDirectory, files,
+			toInteractorReader, toSubmissionWriter, formatTimeLimit(interactorTimeLimit), interactor.checker.memoryLimitInByte)
+		toSubmissionWriter.Close()
+		toInteractorReader.CloseWithError(io.ErrClosedPipe)
+	}()
+	wg.Wait()
+
+	if submissionErr != nil {
+		return RunOutput{}, RunOutput{}, submissionErr
+	}
+	if interactorErr != nil {
+		return RunOutput{}, RunOutput{}, interactorErr
+	}
+	return submissionOutput, interactorOutput, nil
+}
+
+// classifyInteraction combines both sides of a test. A wrong answer from
+// the interactor wins, since the submission may well crash or hang once the
+// interactor stopped talking to it; otherwise the submission's own failures
+// come before any failure of the interactor.
+func classifyInteraction(output RunOutput, interactorOutput RunOutput) (db.TestResult, error) {
+	interactorResult, interactorErr := classifyCheckerOutput(interactorOutput)
+	if interactorErr == nil && interactorResult.Result == db.SubmissionResultWrongAnswer {
+		return interactorResult, nil
+	}
+	if testResult, failed := classifyRunFailure(output); failed {
+		return testResult, nil
+	}
+	if interactorErr != nil {
+		return db.TestResult{}, interactorErr
+	}
+	return interactorResult, nil
+}
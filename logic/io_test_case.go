@@ -0,0 +1,94 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"example/server/db"
+	"example/server/handlers/models"
+	"example/server/utils"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type IOTestCase interface {
+	CreateIOTestCase(ctx context.Context, in *models.CreateIOTestCaseRequest) (*models.CreateIOTestCaseResponse, error)
+	GetIOTestCaseList(ctx context.Context, in *models.GetIOTestCaseListRequest) (*models.GetIOTestCaseListResponse, error)
+	DeleteIOTestCase(ctx context.Context, in *models.DeleteIOTestCaseRequest) error
+}
+
+type ioTestCase struct {
+	logger                 *zap.Logger
+	ioTestCaseDataAccessor db.IOTestCaseDataAccessor
+	problemDataAccessor    db.ProblemDataAccessor
+}
+
+func (i *ioTestCase) CreateIOTestCase(ctx context.Context, in *models.CreateIOTestCaseRequest) (*models.CreateIOTestCaseResponse, error) {
+	problem, err := i.problemDataAccessor.GetProblemByUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		i.logger.Error("fail to get problem by uuid", zap.String("problemUUID", in.ProblemUUID))
+		return nil, err
+	}
+	if problem.JudgeMode != db.ProblemJudgeModeStandardIO {
+		return nil, fmt.Errorf("problem %s is not judged by standard input/output", in.ProblemUUID)
+	}
+	// Tests added after the subtasks have to name existing ones; before
+	// that, setting the subtasks checks them.
+	if len(problem.Subtasks) > 0 {
+		for _, name := range in.Subtasks {
+			if !slices.ContainsFunc(problem.Subtasks, func(subtask db.Subtask) bool { return subtask.Name == name }) {
+				return nil, fmt.Errorf("%w: subtask %q isn't defined", ErrInvalidSubtasks, name)
+			}
+		}
+	}
+
+	order := in.Order
+	if order == 0 {
+		existing, err := i.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, in.ProblemUUID)
+		if err != nil {
+			return nil, err
+		}
+		order = len(existing) + 1
+	}
+
+	newIOTestCase := &db.IOTestCase{
+		UUID:          uuid.NewString(),
+		OfProblemUUID: in.ProblemUUID,
+		Order:         order,
+		Input:         in.Input,
+		Answer:        in.Answer,
+		CreatedAt:     utils.FormatTime(time.Now()),
+		IsHidden:      in.IsHidden,
+		Subtasks:      in.Subtasks,
+	}
+	err = i.ioTestCaseDataAccessor.CreateIOTestCase(ctx, newIOTestCase)
+	if err != nil {
+		i.logger.Error("fail to create io test case", zap.String("problemUUID", in.ProblemUUID), zap.Error(err))
+		return nil, err
+	}
+	return &models.CreateIOTestCaseResponse{UUID: newIOTestCase.UUID, Order: newIOTestCase.Order}, nil
+}
+
+func (i *ioTestCase) GetIOTestCaseList(ctx context.Context, in *models.GetIOTestCaseListRequest) (*models.GetIOTestCaseListResponse, error) {
+	ioTestCases, err := i.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		i.logger.Error("fail to get io test cases by problem uuid", zap.String("problemUUID", in.ProblemUUID))
+		return &models.GetIOTestCaseListResponse{}, err
+	}
+	return &models.GetIOTestCaseListResponse{IOTestCaseList: ioTestCases}, nil
+}
+
+func (i *ioTestCase) DeleteIOTestCase(ctx context.Context, in *models.DeleteIOTestCaseRequest) error {
+	return i.ioTestCaseDataAccessor.DeleteIOTestCase(ctx, in.UUID)
+}
+
+func NewIOTestCaseLogic(logger *zap.Logger, ioTestCaseDataAccessor db.IOTestCaseDataAccessor, problemDataAccessor db.ProblemDataAccessor) IOTestCase {
+	return &ioTestCase{
+		logger:                 logger,
+		ioTestCaseDataAccessor: ioTestCaseDataAccessor,
+		problemDataAccessor:    problemDataAccessor,
+	}
+}
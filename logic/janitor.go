@@ -0,0 +1,256 @@
+package logic
+
+import (
+	"context"
+	"example/server/configs"
+	"example/server/db"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Labels put on every judge container, naming the judge instance that owns it.
+const (
+	judgeContainerLabel         = "coodbox.judge"
+	judgeContainerHostLabel     = "coodbox.host"
+	judgeContainerInstanceLabel = "coodbox.instance"
+)
+
+// instanceID names this run of the judge process. A judge restarted in its
+// container comes back with the same hostname and PID, so those can't tell
+// the leftovers of a crashed instance from its own.
+var instanceID = strings.ReplaceAll(uuid.NewString(), "-", "")
+
+// orphanedSubmissionGracePeriod leaves time for a submission that was just
+// created to get its job enqueued.
+const orphanedSubmissionGracePeriod = time.Minute
+
+// missedHeartbeats is how many janitor passes in a row an instance may miss
+// before other instances take it for dead and remove what it left behind.
+const missedHeartbeats = 3
+
+func judgeContainerLabels() map[string]string {
+	hostname, _ := os.Hostname()
+	return map[string]string{
+		judgeContainerLabel:         "true",
+		judgeContainerHostLabel:     hostname,
+		judgeContainerInstanceLabel: instanceID,
+	}
+}
+
+// Janitor recovers the judge state a crash leaves behind: submissions that
+// lost their job, run containers and temp workspaces.
+type Janitor interface {
+	Reconcile(ctx context.Context)
+	Start(ctx context.Context)
+}
+
+type janitor struct {
+	logger                    *zap.Logger
+	docker                    *client.Client
+	judge                     Judge
+	submissionDataAccessor    db.SubmissionDataAccessor
+	submissionJobDataAccessor db.SubmissionJobDataAccessor
+	judgeInstanceDataAccessor db.JudgeInstanceDataAccessor
+	interval                  time.Duration
+	staleAfter                time.Duration
+	hostname                  string
+}
+
+func NewJanitorLogic(logger *zap.Logger,
+	docker *client.Client,
+	judgeConfig *configs.Judge,
+	judge Judge,
+	submissionDataAccessor db.SubmissionDataAccessor,
+	submissionJobDataAccessor db.SubmissionJobDataAccessor,
+	judgeInstanceDataAccessor db.JudgeInstanceDataAccessor,
+) (Janitor, error) {
+	interval, err := judgeConfig.Janitor.GetIntervalDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse janitor interval")
+		return nil, err
+	}
+	staleAfter, err := judgeConfig.Janitor.GetStaleAfterDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse janitor stale after")
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	return &janitor{
+		logger:                    logger,
+		docker:                    docker,
+		judge:                     judge,
+		submissionDataAccessor:    submissionDataAccessor,
+		submissionJobDataAccessor: submissionJobDataAccessor,
+		judgeInstanceDataAccessor: judgeInstanceDataAccessor,
+		interval:                  interval,
+		staleAfter:                staleAfter,
+		hostname:                  hostname,
+	}, nil
+}
+
+// Reconcile runs every cleanup once. Each one only logs its failures, so
+// one broken dependency doesn't hold back the others.
+func (j *janitor) Reconcile(ctx context.Context) {
+	liveInstances := j.liveInstances(ctx)
+	j.requeueOrphanedSubmissions(ctx)
+	j.removeLeftoverContainers(ctx, liveInstances)
+	j.purgeStaleWorkspaces(liveInstances)
+}
+
+// Start reconciles on the configured interval until ctx is done.
+func (j *janitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.Reconcile(ctx)
+		}
+	}
+}
+
+// requeueOrphanedSubmissions enqueues a new job for every unfinished
+// submission without a queued or leased one. Expired leases need no help:
+// the queue hands their jobs out again by itself.
+func (j *janitor) requeueOrphanedSubmissions(ctx context.Context) {
+	createdBefore := time.Now().Add(-orphanedSubmissionGracePeriod).UnixMilli()
+	submissions, err := j.submissionDataAccessor.GetUnfinishedSubmissions(ctx, createdBefore)
+	if err != nil {
+		j.logger.Error("fail to list unfinished submissions", zap.Error(err))
+		return
+	}
+	for _, submission := range submissions {
+		active, err := j.submissionJobDataAccessor.HasActiveJob(ctx, submission.UUID)
+		if err != nil {
+			j.logger.Error("fail to look up submission jobs", zap.String("submissionUUID", submission.UUID), zap.Error(err))
+			continue
+		}
+		if active {
+			continue
+		}
+		// Another judge's janitor may have seen the same orphan; only the one
+		// that claims it enqueues the job.
+		claimed, err := j.submissionDataAccessor.ClaimOrphanedSubmission(ctx, submission.UUID, submission.RequeuedTime)
+		if err != nil || !claimed {
+			continue
+		}
+		j.logger.Warn("requeueing orphaned submission", zap.String("submissionUUID", submission.UUID))
+		if err := j.judge.ScheduleJudgeLocalSubmission(ctx, submission.UUID, submission.Language); err != nil {
+			j.logger.Error("fail to requeue orphaned submission", zap.String("submissionUUID", submission.UUID), zap.Error(err))
+		}
+	}
+}
+
+// liveInstances renews this instance's heartbeat and returns the instances
+// that sent one recently. It returns nil when they can't be told, and then
+// nothing of other instances is removed.
+func (j *janitor) liveInstances(ctx context.Context) map[string]bool {
+	if err := j.judgeInstanceDataAccessor.Heartbeat(ctx, instanceID, j.hostname); err != nil {
+		return nil
+	}
+	heartbeatAfter := time.Now().Add(-j.heartbeatTimeout()).UnixMilli()
+	if err := j.judgeInstanceDataAccessor.DeleteStaleInstances(ctx, heartbeatAfter); err != nil {
+		return nil
+	}
+	instanceIDs, err := j.judgeInstanceDataAccessor.GetLiveInstanceIDs(ctx, heartbeatAfter)
+	if err != nil {
+		return nil
+	}
+	liveInstances := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		liveInstances[id] = true
+	}
+	return liveInstances
+}
+
+func (j *janitor) heartbeatTimeout() time.Duration {
+	return missedHeartbeats * j.interval
+}
+
+// isOwnerGone reports whether a container or workspace created at createdAt
+// belongs to an instance that is no longer alive. Anything younger than the
+// heartbeat timeout is kept, as its instance may not have sent its first
+// heartbeat yet.
+func (j *janitor) isOwnerGone(liveInstances map[string]bool, owner string, createdAt time.Time) bool {
+	if owner == instanceID || liveInstances == nil || liveInstances[owner] {
+		return false
+	}
+	return time.Since(createdAt) > j.heartbeatTimeout()
+}
+
+// removeLeftoverContainers removes the judge containers that dead instances
+// on this host left behind. Containers of other hosts sharing the daemon are
+// left to their own janitor.
+func (j *janitor) removeLeftoverContainers(ctx context.Context, liveInstances map[string]bool) {
+	if j.docker == nil {
+		return
+	}
+	containers, err := j.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", judgeContainerLabel)),
+	})
+	if err != nil {
+		j.logger.Error("fail to list judge containers", zap.Error(err))
+		return
+	}
+	for _, leftover := range containers {
+		if leftover.Labels[judgeContainerHostLabel] != j.hostname {
+			continue
+		}
+		if !j.isOwnerGone(liveInstances, leftover.Labels[judgeContainerInstanceLabel], time.Unix(leftover.Created, 0)) {
+			continue
+		}
+		j.logger.Warn("removing leftover judge container", zap.String("containerID", leftover.ID))
+		err := j.docker.ContainerRemove(ctx, leftover.ID, container.RemoveOptions{Force: true})
+		if err != nil {
+			j.logger.Error("fail to remove leftover judge container", zap.String("containerID", leftover.ID), zap.Error(err))
+		}
+	}
+}
+
+// purgeStaleWorkspaces removes the workspaces of dead instances, and this
+// instance's run workspaces that outlived any run. Workspaces of other live
+// instances sharing the temp directory are left to their own janitor.
+func (j *janitor) purgeStaleWorkspaces(liveInstances map[string]bool) {
+	for _, prefix := range []string{workspaceDirPrefix, pooledWorkspaceDirPrefix} {
+		dirs, err := filepath.Glob(filepath.Join(os.TempDir(), prefix+"*"))
+		if err != nil {
+			j.logger.Error("fail to list workspaces", zap.Error(err))
+			return
+		}
+		for _, dir := range dirs {
+			if !j.isStaleWorkspace(dir, prefix, liveInstances) {
+				continue
+			}
+			j.logger.Warn("removing stale workspace", zap.String("dir", dir))
+			if err := os.RemoveAll(dir); err != nil {
+				j.logger.Error("fail to remove stale workspace", zap.String("dir", dir), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (j *janitor) isStaleWorkspace(dir string, prefix string, liveInstances map[string]bool) bool {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false
+	}
+	owner, _, _ := strings.Cut(strings.TrimPrefix(filepath.Base(dir), prefix), "-")
+	if owner != instanceID {
+		return j.isOwnerGone(liveInstances, owner, info.ModTime())
+	}
+	if prefix == pooledWorkspaceDirPrefix {
+		return false
+	}
+	return time.Since(info.ModTime()) > j.staleAfter
+}
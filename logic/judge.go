@@ -2,33 +2,83 @@ package logic
 
 import (
 	"context"
+	"errors"
 	"example/server/configs"
 	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"example/server/db"
+	"example/server/handlers/models"
 
 	"github.com/docker/docker/client"
-	"github.com/gammazero/workerpool"
+	"github.com/dustin/go-humanize"
+	"github.com/google/uuid"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
+// errProblemMisconfigured marks judge failures caused by the problem's setup,
+// such as a setter program that doesn't compile. Retrying can't fix them.
+var errProblemMisconfigured = errors.New("problem can't be judged")
+
+// internalErrorGradingResult is all a submission the judge failed to grade
+// shows; the cause stays in the logs and the job's last error.
+const internalErrorGradingResult = "The judge could not grade this submission."
+
 type Judge interface {
-	ScheduleJudgeLocalSubmission(submissionUUID string)
+	ScheduleJudgeLocalSubmission(ctx context.Context, submissionUUID string, language string) error
+	ScheduleRejudgeSubmission(ctx context.Context, submissionUUID string, language string, requestedBy string) error
+	HasActiveJob(ctx context.Context, submissionUUID string) (bool, error)
+	CancelSubmission(ctx context.Context, submissionUUID string) (int64, int64, error)
+	GetLanguageReadiness(ctx context.Context) (*models.GetLanguageReadinessResponse, error)
+	CheckLanguageReadiness(language string) error
+	IsLanguageSupported(language string) bool
+	CheckSubmissionFiles(content string, files []db.SubmissionFile) error
+	Start(ctx context.Context)
+	GetContainerPoolHealth(ctx context.Context) (*models.GetContainerPoolHealthResponse, error)
+	RunCode(ctx context.Context, username string, request *models.RunCodeRequest) (*models.RunCodeResponse, error)
 }
 
 type judge struct {
-	logger                     *zap.Logger
-	workerPool                 *workerpool.WorkerPool
-	db                         *mongo.Client
-	languageToTestCaseRunLogic map[string]TestCaseRun
-	judgeConfig                *configs.Judge
-	submissionDataAccessor     db.SubmissionDataAccessor
-	testDataAccessor           db.TestCaseDataAccessor
-	problemDataAccessor        db.ProblemDataAccessor
+	logger                       *zap.Logger
+	db                           *mongo.Client
+	languageToTestCaseRunLogic   map[string]TestCaseRun
+	languageToStandardIORunLogic map[string]TestCaseRun
+	languageToCompileLogic       map[string]Compile
+	languageToHarnessCompile     map[string]Compile
+	languageToChecker            map[string]*languageChecker
+	languageToInteractor         map[string]*languageChecker
+	judgeConfig                  *configs.Judge
+	submissionDataAccessor       db.SubmissionDataAccessor
+	testDataAccessor             db.TestCaseDataAccessor
+	problemDataAccessor          db.ProblemDataAccessor
+	submissionJobDataAccessor    db.SubmissionJobDataAccessor
+	ioTestCaseDataAccessor       db.IOTestCaseDataAccessor
+	problemFixtureDataAccessor   db.ProblemFixtureDataAccessor
+	judgeLogDataAccessor         db.JudgeLogDataAccessor
+	workerID                     string
+	pollInterval                 time.Duration
+	retryDelay                   time.Duration
+	visibilityTimeout            time.Duration
+	maxAttempts                  int
+	workers                      int
+	languageLimiter              *languageLimiter
+	runningJobs                  *runningJobs
+	runCodeRateLimiter           *rateLimiter
+	runCodeMaxInputSize          uint64
+	submissionMaxSize            uint64
+	gradingResultLimitInByte     uint64
+	archiveLimitInByte           uint64
 }
 
+// languageBusyDelay is how long a job stays back in the queue after a worker
+// claimed it while its language was already at its concurrency cap.
+const languageBusyDelay = time.Second
+
 func NewJudgeLogic(logger *zap.Logger,
 	db *mongo.Client,
 	docker *client.Client,
@@ -36,47 +86,250 @@ func NewJudgeLogic(logger *zap.Logger,
 	submissionDataAccessor db.SubmissionDataAccessor,
 	testDataAcessor db.TestCaseDataAccessor,
 	problemDataAccessor db.ProblemDataAccessor,
-
+	submissionJobDataAccessor db.SubmissionJobDataAccessor,
+	ioTestCaseDataAccessor db.IOTestCaseDataAccessor,
+	problemFixtureDataAccessor db.ProblemFixtureDataAccessor,
+	judgeLogDataAccessor db.JudgeLogDataAccessor,
 ) (Judge, error) {
+	pollInterval, err := judgeConfig.GetScheduleInterval()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse judge schedule")
+		return nil, err
+	}
+	retryDelay, err := judgeConfig.GetSubmissionRetryDelayDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse submission retry delay")
+		return nil, err
+	}
+	visibilityTimeout, err := judgeConfig.GetVisibilityTimeoutDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse visibility timeout")
+		return nil, err
+	}
+	maxAttempts := judgeConfig.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	workers := judgeConfig.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	pinnedCPUs, err := judgeConfig.GetPinnedCPUs()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse pinned cpus")
+		return nil, err
+	}
+	if len(pinnedCPUs) > 0 && len(pinnedCPUs) < workers {
+		logger.Warn("fewer pinned cpus than workers, runs will wait for a free core",
+			zap.Int("workers", workers), zap.Strings("pinnedCPUs", pinnedCPUs))
+	}
+	cpuSetPool := newCPUSetPool(pinnedCPUs)
+	languageLimits := make(map[string]int)
+	for _, language := range judgeConfig.Languages {
+		if language.MaxConcurrency > 0 {
+			languageLimits[language.Value] = language.MaxConcurrency
+		}
+	}
+	runCodeWindow, err := judgeConfig.RunCode.GetRateLimitWindowDuration()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse run code rate limit window")
+		return nil, err
+	}
+	runCodeMaxInputSize, err := judgeConfig.RunCode.GetMaxInputSizeInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse run code max input size")
+		return nil, err
+	}
+	submissionMaxSize, err := judgeConfig.Files.GetMaxSubmissionSizeInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse max submission size")
+		return nil, err
+	}
+	outputLimitInByte, err := judgeConfig.Output.GetRunLimitInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse run output limit")
+		return nil, err
+	}
+	gradingResultLimitInByte, err := judgeConfig.Output.GetGradingResultLimitInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse grading result limit")
+		return nil, err
+	}
+	archiveLimitInByte, err := judgeConfig.Output.GetArchiveLimitInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse judge log archive limit")
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
 
 	j := &judge{
-		logger:                     logger,
-		workerPool:                 workerpool.New(1),
-		db:                         db,
-		judgeConfig:                judgeConfig,
-		languageToTestCaseRunLogic: make(map[string]TestCaseRun),
-		submissionDataAccessor:     submissionDataAccessor,
-		testDataAccessor:           testDataAcessor,
-		problemDataAccessor:        problemDataAccessor,
+		logger:                       logger,
+		db:                           db,
+		judgeConfig:                  judgeConfig,
+		languageToTestCaseRunLogic:   make(map[string]TestCaseRun),
+		languageToStandardIORunLogic: make(map[string]TestCaseRun),
+		languageToCompileLogic:       make(map[string]Compile),
+		languageToHarnessCompile:     make(map[string]Compile),
+		languageToChecker:            make(map[string]*languageChecker),
+		languageToInteractor:         make(map[string]*languageChecker),
+		submissionDataAccessor:       submissionDataAccessor,
+		testDataAccessor:             testDataAcessor,
+		problemDataAccessor:          problemDataAccessor,
+		submissionJobDataAccessor:    submissionJobDataAccessor,
+		ioTestCaseDataAccessor:       ioTestCaseDataAccessor,
+		problemFixtureDataAccessor:   problemFixtureDataAccessor,
+		judgeLogDataAccessor:         judgeLogDataAccessor,
+		workerID:                     fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()),
+		pollInterval:                 pollInterval,
+		retryDelay:                   retryDelay,
+		visibilityTimeout:            visibilityTimeout,
+		maxAttempts:                  maxAttempts,
+		workers:                      workers,
+		languageLimiter:              newLanguageLimiter(languageLimits),
+		runningJobs:                  newRunningJobs(),
+		runCodeRateLimiter:           newRateLimiter(judgeConfig.RunCode.RateLimit, runCodeWindow),
+		runCodeMaxInputSize:          runCodeMaxInputSize,
+		submissionMaxSize:            submissionMaxSize,
+		gradingResultLimitInByte:     gradingResultLimitInByte,
+		archiveLimitInByte:           archiveLimitInByte,
 	}
 
 	for _, language := range judgeConfig.Languages {
+		if language.Compile != nil {
+			compileLogic, err := NewCompileLogic(docker, logger, language.Value, language.Compile, judgeConfig.DependencyCacheDir, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new compile logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToCompileLogic[language.Value] = compileLogic
+		}
 
-		testCaseRun, err := NewTestCaseRunLogic(docker, logger, language.Value, &language.TestCaseRun)
-		if err != nil {
-			logger.Error("fail to make new test case logic")
+		if language.TestCaseRun.Image != "" {
+			testCaseRun, err := NewTestCaseRunLogic(docker, logger, language.Value, runModeTestHarness, &language.TestCaseRun, judgeConfig.DependencyCacheDir, cpuSetPool, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new test case logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToTestCaseRunLogic[language.Value] = testCaseRun
+			logger.Info("created test run logic", zap.Any("language", language.Name))
+		}
+
+		if language.TestCaseRun.Compile != nil {
+			harnessCompile, err := NewCompileLogic(docker, logger, language.Value, language.TestCaseRun.Compile, judgeConfig.DependencyCacheDir, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new harness compile logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToHarnessCompile[language.Value] = harnessCompile
+		}
+
+		if language.StandardIORun != nil {
+			standardIORun, err := NewTestCaseRunLogic(docker, logger, language.Value, runModeStandardIO, language.StandardIORun, judgeConfig.DependencyCacheDir, cpuSetPool, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new standard io run logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToStandardIORunLogic[language.Value] = standardIORun
+		}
+
+		if language.Checker != nil {
+			checker, err := newLanguageChecker(docker, logger, language.Value, runModeChecker, language.Checker, judgeConfig.DependencyCacheDir, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new checker logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToChecker[language.Value] = checker
+		}
+
+		if language.Interactor != nil {
+			interactor, err := newLanguageChecker(docker, logger, language.Value, runModeInteractor, language.Interactor, judgeConfig.DependencyCacheDir, outputLimitInByte)
+			if err != nil {
+				logger.Error("fail to make new interactor logic", zap.String("language", language.Value), zap.Error(err))
+				return nil, err
+			}
+			j.languageToInteractor[language.Value] = interactor
 		}
-		j.languageToTestCaseRunLogic[language.Value] = testCaseRun
-		logger.Info("created test run logic", zap.Any("language", language.Name))
 	}
 
 	return j, nil
 }
 
+// judgeVerdict is what a judging mode produces for a submission.
+type judgeVerdict struct {
+	Result        db.SubmissionResult
+	TestResults   []db.TestResult
+	TestSummary   *db.TestSummary
+	GradingResult string
+	// HidesTestDetails is set when GradingResult may reveal hidden tests.
+	HidesTestDetails bool
+	// Log is the full output of the runs, archived apart from the
+	// submission.
+	Log                   *outputBuffer
+	TimeInMillisecond     int64
+	WallTimeInMillisecond int64
+	MemoryInByte          uint64
+}
+
+// recordUsage keeps the maximum usage across the runs of a submission.
+func (v *judgeVerdict) recordUsage(output RunOutput) {
+	v.TimeInMillisecond = max(v.TimeInMillisecond, runTimeInMillisecond(output))
+	v.WallTimeInMillisecond = max(v.WallTimeInMillisecond, output.WallTimeInMillisecond)
+	v.MemoryInByte = max(v.MemoryInByte, output.PeakMemoryInByte)
+}
+
+// addTestResult records one test of a submission judged test by test: its
+// usage, its line in the grading log, its output in the full log and, for the
+// first failing test, the verdict.
+func (v *judgeVerdict) addTestResult(testResult db.TestResult, output RunOutput) {
+	testResult.TimeInMillisecond = runTimeInMillisecond(output)
+	testResult.MemoryInByte = output.PeakMemoryInByte
+	v.TestResults = append(v.TestResults, testResult)
+	v.recordUsage(output)
+	if v.GradingResult != "" {
+		v.GradingResult += "\n"
+	}
+	v.GradingResult += fmt.Sprintf("%s: %s (%d ms, %s)",
+		testResult.Name, describeResult(testResult.Result), testResult.TimeInMillisecond, humanize.IBytes(testResult.MemoryInByte))
+	if v.Log != nil {
+		fmt.Fprintf(v.Log, "==== %s: %s ====\n", testResult.Name, describeResult(testResult.Result))
+		writeJudgeLogSection(v.Log, "stdout", output.StdOut)
+		writeJudgeLogSection(v.Log, "stderr", output.StdErr)
+	}
+	if v.Result == db.SubmissionResultOK && testResult.Result != db.SubmissionResultOK {
+		v.Result = testResult.Result
+	}
+}
+
+// runTimeInMillisecond reports CPU time, falling back to wall time when the
+// stats stream ended before producing a sample.
+func runTimeInMillisecond(output RunOutput) int64 {
+	if output.CPUTimeInMillisecond > 0 {
+		return output.CPUTimeInMillisecond
+	}
+	return output.WallTimeInMillisecond
+}
+
+func formatTimeLimit(timeLimitInMillisecond uint64) string {
+	return fmt.Sprintf("%.3fs", float64(timeLimitInMillisecond)/1000)
+}
+
 func (j judge) judgeSubmission(
 	ctx context.Context,
 	language string,
-	submissionCodeSnippet string,
+	source RunSource,
+	programDirectory string,
 	testCodeSnippet string,
 	timeLimitInMillisecond uint64,
 	memoryInByte uint64) (RunOutput, error) {
 
-	timeLimitInSecond := fmt.Sprintf("%.3fs", float64(timeLimitInMillisecond)/1000)
+	timeLimitInSecond := formatTimeLimit(timeLimitInMillisecond)
 	j.logger.Info("getting timeout", zap.Any("timeoutinMiniSecond", timeLimitInMillisecond), zap.Any("timeoutInSecond", timeLimitInSecond))
-	if j.languageToTestCaseRunLogic[language] == nil {
+	runLogic := j.languageToTestCaseRunLogic[language]
+	if runLogic == nil {
 		j.logger.Error("nil test case logic", zap.Any("test case language", language))
+		return RunOutput{}, fmt.Errorf("no test case run logic for language %s", language)
 	}
-	output, err := j.languageToTestCaseRunLogic[language].Run(ctx, testCodeSnippet, submissionCodeSnippet, timeLimitInSecond, memoryInByte)
+	output, err := runLogic.Run(ctx, testCodeSnippet, source, programDirectory, timeLimitInSecond, memoryInByte)
 	if err != nil {
 		return RunOutput{}, err
 	}
@@ -86,45 +339,536 @@ func (j judge) judgeSubmission(
 	return output, nil
 }
 
-func (j judge) judgeLocalSubmission(ctx context.Context, submissionUUID string) {
+func (j judge) judgeLocalSubmission(ctx context.Context, job *db.SubmissionJob) error {
+	submissionUUID := job.SubmissionUUID
 	submissionDB, err := j.submissionDataAccessor.GetSubmissionByUUID(ctx, submissionUUID)
 	if err != nil {
 		j.logger.Error("fail to get submissionUUID", zap.Error(err))
-		return
+		return err
+	}
+	if submissionDB.Status == db.SubmissionStatusFinished && !job.Rejudge {
+		j.logger.Info("submission already judged, skipping", zap.String("submissionUUID", submissionUUID))
+		return nil
+	}
+	if !j.IsLanguageSupported(submissionDB.Language) {
+		return j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, submissionUUID, unsupportedLanguageUpdate(submissionDB.Language))
 	}
 	problem, err := j.problemDataAccessor.GetProblemByUUID(ctx, submissionDB.ProblemUUID)
 	if err != nil {
 		j.logger.Error("fail to get problem by UUID", zap.Error(err), zap.Any("problemUUID", submissionDB.ProblemUUID))
-		return
+		return err
 	}
-	testCase, err := j.testDataAccessor.GetTestCaseByProblemUUIDAndLanguage(ctx, problem.UUID, submissionDB.Language)
+	source, err := j.runSource(ctx, submissionDB.ProblemUUID, submissionDB.Content, submissionDB.Files)
 	if err != nil {
-		j.logger.Error("fail to get test case by problemUUID", zap.Error(err), zap.Any("problemUUID", problem.UUID))
-		return
+		return err
+	}
+
+	err = j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, submissionUUID, map[string]any{"status": db.SubmissionStatusExecuting})
+	if err != nil {
+		return err
 	}
 
-	output, err := j.judgeSubmission(ctx, submissionDB.Language, submissionDB.Content, testCase.TestFileContent, problem.TimeLimitInMillisecond, problem.MemoryLimitInByte)
+	var verdict judgeVerdict
+	programDirectory, compileVerdict, err := j.compileForProblem(ctx, submissionDB, problem)
 	if err != nil {
 		j.logger.Error(err.Error())
-		return
+		return err
+	}
+	if programDirectory != "" {
+		defer os.RemoveAll(programDirectory)
+	}
+	switch {
+	case compileVerdict != nil:
+		verdict = *compileVerdict
+	case problem.JudgeMode == db.ProblemJudgeModeStandardIO:
+		verdict, err = j.judgeStandardIOSubmission(ctx, submissionDB, problem, source, programDirectory)
+	case problem.JudgeMode == db.ProblemJudgeModeInteractive:
+		verdict, err = j.judgeInteractiveSubmission(ctx, submissionDB, problem, source, programDirectory)
+	default:
+		verdict, err = j.judgeTestHarnessSubmission(ctx, submissionDB, problem, source, programDirectory)
+	}
+	if err != nil {
+		j.logger.Error(err.Error())
+		return err
 	}
 
-	j.updateSubmission(ctx, submissionUUID, output.ReturnLog, db.SubmissionStatusFinished)
+	j.logger.Info("judged submission",
+		zap.String("submissionUUID", submissionUUID),
+		zap.Any("result", verdict.Result))
+	logFileID := j.archiveJudgeLog(ctx, submissionUUID, verdict.Log)
+	score, maxScore, subtaskScores := scoreSubmission(problem.Subtasks, verdict.Result, verdict.TestResults)
+	update := map[string]any{
+		"grading_result":        truncateGradingResult(verdict.GradingResult, j.gradingResultLimitInByte, logFileID != ""),
+		"logFileID":             logFileID,
+		"status":                db.SubmissionStatusFinished,
+		"result":                verdict.Result,
+		"testResults":           verdict.TestResults,
+		"testSummary":           verdict.TestSummary,
+		"score":                 score,
+		"maxScore":              maxScore,
+		"subtaskScores":         subtaskScores,
+		"hidesTestDetails":      verdict.HidesTestDetails,
+		"timeInMillisecond":     verdict.TimeInMillisecond,
+		"wallTimeInMillisecond": verdict.WallTimeInMillisecond,
+		"memoryInByte":          verdict.MemoryInByte,
+		"judged_time":           time.Now().UnixMilli(),
+	}
+	// Submissions judged before results were recorded have no Result, but
+	// their verdict still goes to the history. A rejudge retried after a
+	// crash finds the submission executing, with its old verdict still set.
+	if job.Rejudge && (submissionDB.Status == db.SubmissionStatusFinished || submissionDB.JudgedTime != 0) {
+		return j.submissionDataAccessor.ReplaceSubmissionVerdict(ctx, submissionUUID, update, db.SubmissionVerdict{
+			Result:                submissionDB.Result,
+			GradingResult:         submissionDB.GradingResult,
+			TestResults:           submissionDB.TestResults,
+			TestSummary:           submissionDB.TestSummary,
+			Score:                 submissionDB.Score,
+			MaxScore:              submissionDB.MaxScore,
+			SubtaskScores:         submissionDB.SubtaskScores,
+			HidesTestDetails:      submissionDB.HidesTestDetails,
+			TimeInMillisecond:     submissionDB.TimeInMillisecond,
+			WallTimeInMillisecond: submissionDB.WallTimeInMillisecond,
+			MemoryInByte:          submissionDB.MemoryInByte,
+			JudgedTime:            submissionDB.JudgedTime,
+			LogFileID:             submissionDB.LogFileID,
+			ReplacedTime:          time.Now().UnixMilli(),
+			RejudgedBy:            job.RequestedBy,
+		})
+	}
+	return j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, submissionUUID, update)
 }
 
-func (j judge) updateSubmission(ctx context.Context, uuid string, gradingResult string, status db.SubmissionStatus) error {
+// IsLanguageSupported reports whether the language is configured.
+func (j judge) IsLanguageSupported(language string) bool {
+	return j.judgeConfig.GetLanguage(language) != nil
+}
 
-	update := map[string]any{
-		"grading_result": gradingResult,
-		"status":         status,
+// unsupportedLanguageUpdate finishes a submission in a language the judge
+// isn't configured for.
+func unsupportedLanguageUpdate(language string) map[string]any {
+	return map[string]any{
+		"status":         db.SubmissionStatusFinished,
+		"result":         db.SubmissionResultUnsupportedLanguage,
+		"grading_result": fmt.Sprintf("language %s is not supported", language),
+		"judged_time":    time.Now().UnixMilli(),
+	}
+}
+
+// internalErrorUpdate finishes a submission the judge failed to grade.
+func internalErrorUpdate() map[string]any {
+	return map[string]any{
+		"status":         db.SubmissionStatusFinished,
+		"result":         db.SubmissionResultInternalError,
+		"grading_result": internalErrorGradingResult,
+		"judged_time":    time.Now().UnixMilli(),
+	}
+}
+
+// runSource gathers what every run of a submission puts in its workspace.
+func (j judge) runSource(ctx context.Context, problemUUID string, content string, files []db.SubmissionFile) (RunSource, error) {
+	fixtures, err := j.problemFixtureDataAccessor.GetProblemFixturesByProblemUUID(ctx, problemUUID)
+	if err != nil {
+		j.logger.Error("fail to get problem fixtures", zap.String("problemUUID", problemUUID), zap.Error(err))
+		return RunSource{}, err
+	}
+	return RunSource{Content: content, Files: files, Fixtures: fixtures}, nil
+}
+
+// compileForProblem builds the submission the way the problem runs it. Test
+// harness problems in languages whose tests are compiled in build the
+// submission together with the setter's test file.
+func (j judge) compileForProblem(ctx context.Context, submission *db.Submission, problem *db.Problem) (string, *judgeVerdict, error) {
+	harnessCompile, ok := j.languageToHarnessCompile[submission.Language]
+	if !ok || problem.JudgeMode == db.ProblemJudgeModeStandardIO || problem.JudgeMode == db.ProblemJudgeModeInteractive {
+		return j.compileSubmission(ctx, submission)
+	}
+	testCase, err := j.testDataAccessor.GetTestCaseByProblemUUIDAndLanguage(ctx, problem.UUID, submission.Language)
+	if err != nil {
+		j.logger.Error("fail to get test case by problemUUID", zap.Error(err), zap.Any("problemUUID", problem.UUID))
+		return "", nil, err
+	}
+	testFileName := j.judgeConfig.GetLanguage(submission.Language).TestCaseRun.TestFileName
+	programDirectory, compileVerdict, err := j.runCompile(ctx, harnessCompile, submission, map[string]string{testFileName: testCase.TestFileContent})
+	if compileVerdict != nil {
+		// Compiler messages quote the test file, hidden tests included.
+		compileVerdict.HidesTestDetails = hasHiddenTests(testCase.TestVisibility)
+	}
+	return programDirectory, compileVerdict, err
+}
+
+// compileSubmission builds the submission once for languages with a compile
+// step. It returns the directory holding the artefacts, or a compile error
+// verdict when the compiler rejected the source.
+func (j judge) compileSubmission(ctx context.Context, submission *db.Submission) (string, *judgeVerdict, error) {
+	compileLogic, ok := j.languageToCompileLogic[submission.Language]
+	if !ok {
+		return "", nil, nil
+	}
+	return j.runCompile(ctx, compileLogic, submission, nil)
+}
+
+// runCompile compiles the submission with all of its files, plus the given
+// extra files.
+func (j judge) runCompile(ctx context.Context, compileLogic Compile, submission *db.Submission, files map[string]string) (string, *judgeVerdict, error) {
+	compileFiles := submissionFileMap(submission.Files)
+	for fileName, content := range files {
+		compileFiles[fileName] = content
+	}
+	compileOutput, err := compileLogic.CompileFiles(ctx, submission.Content, compileFiles)
+	if err != nil {
+		j.logger.Error("fail to compile submission", zap.String("submissionUUID", submission.UUID), zap.Error(err))
+		return "", nil, err
 	}
-	err := j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, uuid, update)
+	if compileOutput.ReturnCode != 0 {
+		os.RemoveAll(compileOutput.WorkingDir)
+		compilerLog := compileOutput.StdErr
+		if compilerLog == "" {
+			compilerLog = compileOutput.StdOut
+		}
+		log := j.newJudgeLog()
+		log.Write([]byte(compilerLog))
+		return "", &judgeVerdict{
+			Result:        db.SubmissionResultCompileError,
+			GradingResult: compilerLog,
+			Log:           log,
+		}, nil
+	}
+	return compileOutput.WorkingDir, nil, nil
+}
+
+// judgeTestHarnessSubmission runs the setter's test file against the submission.
+func (j judge) judgeTestHarnessSubmission(ctx context.Context, submission *db.Submission, problem *db.Problem, source RunSource, programDirectory string) (judgeVerdict, error) {
+	if j.languageToTestCaseRunLogic[submission.Language] == nil {
+		return judgeVerdict{
+			Result:        db.SubmissionResultUnsupportedLanguage,
+			GradingResult: fmt.Sprintf("language %s does not support test harness judging", submission.Language),
+		}, nil
+	}
+	testCase, err := j.testDataAccessor.GetTestCaseByProblemUUIDAndLanguage(ctx, problem.UUID, submission.Language)
+	if err != nil {
+		j.logger.Error("fail to get test case by problemUUID", zap.Error(err), zap.Any("problemUUID", problem.UUID))
+		return judgeVerdict{}, err
+	}
+
+	output, err := j.judgeSubmission(ctx, submission.Language, source, programDirectory, testCase.TestFileContent, problem.TimeLimitInMillisecond, problem.MemoryLimitInByte)
+	if err != nil {
+		return judgeVerdict{}, err
+	}
+	markHiddenTests(output.TestResults, testCase.TestVisibility)
+	assignHarnessSubtasks(output.TestResults, problem.Subtasks)
+	verdict := judgeVerdict{
+		Result:           classifyVerdict(output),
+		TestResults:      output.TestResults,
+		TestSummary:      output.TestSummary,
+		GradingResult:    output.ReturnLog,
+		HidesTestDetails: hasHiddenTests(testCase.TestVisibility),
+		Log:              j.newJudgeLog(),
+	}
+	verdict.Log.Write([]byte(output.ReturnLog))
+	verdict.recordUsage(output)
+	return verdict, nil
+}
+
+// judgeStandardIOSubmission feeds each input of the problem to the submission
+// in order and compares its standard output with the jury answer.
+func (j judge) judgeStandardIOSubmission(ctx context.Context, submission *db.Submission, problem *db.Problem, source RunSource, programDirectory string) (judgeVerdict, error) {
+	runLogic := j.languageToStandardIORunLogic[submission.Language]
+	if runLogic == nil {
+		return judgeVerdict{
+			Result:        db.SubmissionResultUnsupportedLanguage,
+			GradingResult: fmt.Sprintf("language %s does not support standard input/output judging", submission.Language),
+		}, nil
+	}
+	ioTestCases, err := j.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, problem.UUID)
 	if err != nil {
+		return judgeVerdict{}, err
+	}
+	if len(ioTestCases) == 0 {
+		return judgeVerdict{}, fmt.Errorf("%w: problem %s has no input/answer pairs", errProblemMisconfigured, problem.UUID)
+	}
+
+	checker, err := j.prepareChecker(ctx, problem.UUID, problem.Checker, j.languageToChecker)
+	if err != nil {
+		return judgeVerdict{}, err
+	}
+	defer checker.close()
+
+	timeLimitInSecond := formatTimeLimit(problem.TimeLimitInMillisecond)
+	verdict := judgeVerdict{Result: db.SubmissionResultOK, Log: j.newJudgeLog()}
+	for i, ioTestCase := range ioTestCases {
+		output, err := runLogic.RunWithInput(ctx, source, programDirectory, ioTestCase.Input, timeLimitInSecond, problem.MemoryLimitInByte)
+		if err != nil {
+			return judgeVerdict{}, err
+		}
+
+		testResult, failed := classifyRunFailure(output)
+		switch {
+		case failed:
+		case checker != nil:
+			testResult, err = checker.check(ctx, ioTestCase.Input, output.StdOut, ioTestCase.Answer)
+			if err != nil {
+				return judgeVerdict{}, err
+			}
+		default:
+			testResult = compareWithAnswer(output, ioTestCase.Answer)
+		}
+		testResult.Name = fmt.Sprintf("Test #%d", i+1)
+		testResult.Hidden = ioTestCase.IsHidden
+		testResult.Subtasks = ioTestCase.Subtasks
+		verdict.addTestResult(testResult, output)
+	}
+	return verdict, nil
+}
+
+// ScheduleJudgeLocalSubmission persists a job for the submission so that it
+// survives restarts and can be picked up by any running judge.
+func (j judge) ScheduleJudgeLocalSubmission(ctx context.Context, submissionUUID string, language string) error {
+	job := &db.SubmissionJob{
+		UUID:           uuid.NewString(),
+		SubmissionUUID: submissionUUID,
+		Language:       language,
+		Priority:       db.SubmissionJobPriorityNormal,
+	}
+	err := j.submissionJobDataAccessor.EnqueueJob(ctx, job)
+	if err != nil {
+		j.logger.Error("fail to schedule submission", zap.String("submissionUUID", submissionUUID), zap.Error(err))
 		return err
 	}
 	return nil
 }
 
-func (j judge) ScheduleJudgeLocalSubmission(submissionUUID string) {
-	j.workerPool.Submit(func() { j.judgeLocalSubmission(context.Background(), submissionUUID) })
+// ScheduleRejudgeSubmission queues a finished submission to be judged again
+// behind every fresh submission.
+func (j judge) ScheduleRejudgeSubmission(ctx context.Context, submissionUUID string, language string, requestedBy string) error {
+	job := &db.SubmissionJob{
+		UUID:           uuid.NewString(),
+		SubmissionUUID: submissionUUID,
+		Language:       language,
+		Priority:       db.SubmissionJobPriorityRejudge,
+		Rejudge:        true,
+		RequestedBy:    requestedBy,
+	}
+	err := j.submissionJobDataAccessor.EnqueueJob(ctx, job)
+	if err != nil {
+		j.logger.Error("fail to schedule rejudge", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// HasActiveJob reports whether the submission is queued or being judged.
+func (j judge) HasActiveJob(ctx context.Context, submissionUUID string) (bool, error) {
+	return j.submissionJobDataAccessor.HasActiveJob(ctx, submissionUUID)
+}
+
+// CancelSubmission removes the queued jobs of a submission and stops its
+// running ones. It returns how many jobs were removed from the queue and how
+// many were running; a running job marks the submission once it has stopped.
+func (j judge) CancelSubmission(ctx context.Context, submissionUUID string) (int64, int64, error) {
+	removed, running, err := j.submissionJobDataAccessor.CancelJobs(ctx, submissionUUID)
+	if err != nil {
+		j.logger.Error("fail to cancel submission jobs", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return 0, 0, err
+	}
+	if j.runningJobs.cancel(submissionUUID) {
+		j.logger.Info("cancelled running submission", zap.String("submissionUUID", submissionUUID))
+	}
+	return removed, running, nil
+}
+
+// Start runs the configured number of workers, each polling the submission
+// queue on the configured schedule, until ctx is done.
+func (j judge) Start(ctx context.Context) {
+	j.logger.Info("judge started",
+		zap.String("workerID", j.workerID),
+		zap.Int("workers", j.workers),
+		zap.Duration("pollInterval", j.pollInterval))
+	var wg sync.WaitGroup
+	for i := 0; i < j.workers; i++ {
+		wg.Add(1)
+		go func(leaseOwner string) {
+			defer wg.Done()
+			j.runWorker(ctx, leaseOwner)
+		}(fmt.Sprintf("%s-%d", j.workerID, i))
+	}
+	wg.Wait()
+	for _, runLogic := range j.runLogics() {
+		runLogic.Close(context.Background())
+	}
+	j.logger.Info("judge stopped", zap.String("workerID", j.workerID))
+}
+
+func (j judge) runLogics() []TestCaseRun {
+	var runLogics []TestCaseRun
+	for _, runLogic := range j.languageToTestCaseRunLogic {
+		runLogics = append(runLogics, runLogic)
+	}
+	for _, runLogic := range j.languageToStandardIORunLogic {
+		runLogics = append(runLogics, runLogic)
+	}
+	for _, checker := range j.languageToChecker {
+		runLogics = append(runLogics, checker.run)
+	}
+	for _, interactor := range j.languageToInteractor {
+		runLogics = append(runLogics, interactor.run)
+	}
+	return runLogics
+}
+
+// GetContainerPoolHealth reports on every warm container pool, sorted by
+// language and mode.
+func (j judge) GetContainerPoolHealth(ctx context.Context) (*models.GetContainerPoolHealthResponse, error) {
+	response := &models.GetContainerPoolHealthResponse{Pools: []*models.ContainerPoolHealth{}}
+	for _, runLogic := range j.runLogics() {
+		if health := runLogic.PoolHealth(); health != nil {
+			response.Pools = append(response.Pools, health)
+		}
+	}
+	sort.Slice(response.Pools, func(a, b int) bool {
+		if response.Pools[a].Language != response.Pools[b].Language {
+			return response.Pools[a].Language < response.Pools[b].Language
+		}
+		return response.Pools[a].Mode < response.Pools[b].Mode
+	})
+	return response, nil
+}
+
+func (j judge) runWorker(ctx context.Context, leaseOwner string) {
+	ticker := time.NewTicker(j.pollInterval)
+	defer ticker.Stop()
+	for {
+		j.drainQueue(ctx, leaseOwner)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j judge) drainQueue(ctx context.Context, leaseOwner string) {
+	for ctx.Err() == nil {
+		excludedLanguages := append(j.languageLimiter.saturated(), j.unreadyLanguages()...)
+		job, err := j.submissionJobDataAccessor.ClaimNextJob(ctx, leaseOwner, j.visibilityTimeout, excludedLanguages)
+		if err != nil || job == nil {
+			return
+		}
+		if !j.languageLimiter.tryAcquire(job.Language) {
+			if err := j.submissionJobDataAccessor.ReleaseJob(ctx, job.UUID, leaseOwner, languageBusyDelay); err != nil {
+				j.logger.Error("fail to release submission job", zap.String("jobUUID", job.UUID), zap.Error(err))
+			}
+			continue
+		}
+		j.processJob(ctx, leaseOwner, job)
+		j.languageLimiter.release(job.Language)
+	}
+}
+
+func (j judge) processJob(ctx context.Context, leaseOwner string, job *db.SubmissionJob) {
+	j.logger.Info("processing submission job",
+		zap.String("jobUUID", job.UUID),
+		zap.String("submissionUUID", job.SubmissionUUID),
+		zap.String("leaseOwner", leaseOwner),
+		zap.Int("attempt", job.Attempts))
+
+	// A job can go back to the queue for a retry after its cancellation was requested.
+	if job.CancelRequested {
+		j.finishCancelledJob(ctx, leaseOwner, job)
+		return
+	}
+
+	jobCtx, cancelJob := context.WithCancel(ctx)
+	defer cancelJob()
+	j.runningJobs.start(job.SubmissionUUID, cancelJob)
+	defer j.runningJobs.finish(job.SubmissionUUID)
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(jobCtx)
+	defer stopHeartbeat()
+	go j.keepLeaseAlive(heartbeatCtx, leaseOwner, job.UUID, cancelJob)
+
+	judgeErr := j.judgeLocalSubmission(jobCtx, job)
+	stopHeartbeat()
+
+	if judgeErr != nil && jobCtx.Err() != nil && ctx.Err() == nil {
+		j.finishCancelledJob(ctx, leaseOwner, job)
+		return
+	}
+
+	if judgeErr == nil {
+		if err := j.submissionJobDataAccessor.CompleteJob(ctx, job.UUID, leaseOwner); err != nil {
+			j.logger.Error("fail to complete submission job", zap.String("jobUUID", job.UUID), zap.Error(err))
+		}
+		return
+	}
+
+	if job.Attempts < j.maxAttempts && !errors.Is(judgeErr, errProblemMisconfigured) {
+		j.logger.Warn("retrying submission job",
+			zap.String("jobUUID", job.UUID),
+			zap.Int("attempt", job.Attempts),
+			zap.Error(judgeErr))
+		if err := j.submissionJobDataAccessor.RetryJob(ctx, job.UUID, leaseOwner, j.retryDelay, judgeErr.Error()); err != nil {
+			j.logger.Error("fail to requeue submission job", zap.String("jobUUID", job.UUID), zap.Error(err))
+		}
+		return
+	}
+
+	j.logger.Error("submission job failed",
+		zap.String("jobUUID", job.UUID),
+		zap.String("submissionUUID", job.SubmissionUUID),
+		zap.Int("attempt", job.Attempts),
+		zap.Error(judgeErr))
+	if err := j.submissionJobDataAccessor.FailJob(ctx, job.UUID, leaseOwner, judgeErr.Error()); err != nil {
+		j.logger.Error("fail to mark submission job as failed", zap.String("jobUUID", job.UUID), zap.Error(err))
+	}
+	if job.Rejudge {
+		// The previous verdict still stands when a rejudge can't complete.
+		err := j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, job.SubmissionUUID, map[string]any{"status": db.SubmissionStatusFinished})
+		if err != nil {
+			j.logger.Error("fail to restore submission after failed rejudge", zap.String("submissionUUID", job.SubmissionUUID), zap.Error(err))
+		}
+		return
+	}
+	if err := j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, job.SubmissionUUID, internalErrorUpdate()); err != nil {
+		j.logger.Error("fail to finish submission after failed job", zap.String("submissionUUID", job.SubmissionUUID), zap.Error(err))
+	}
+}
+
+// finishCancelledJob records a job stopped by a cancellation. A cancelled
+// rejudge leaves the previous verdict in place.
+func (j judge) finishCancelledJob(ctx context.Context, leaseOwner string, job *db.SubmissionJob) {
+	j.logger.Info("submission job cancelled", zap.String("jobUUID", job.UUID), zap.String("submissionUUID", job.SubmissionUUID))
+	if err := j.submissionJobDataAccessor.FinishCancelledJob(ctx, job.UUID, leaseOwner); err != nil {
+		j.logger.Error("fail to mark submission job as cancelled", zap.String("jobUUID", job.UUID), zap.Error(err))
+	}
+	status := db.SubmissionStatusCancelled
+	if job.Rejudge {
+		status = db.SubmissionStatusFinished
+	}
+	err := j.submissionDataAccessor.UpdateSubmissionByUUID(ctx, job.SubmissionUUID, map[string]any{"status": status})
+	if err != nil {
+		j.logger.Error("fail to mark submission as cancelled", zap.String("submissionUUID", job.SubmissionUUID), zap.Error(err))
+	}
+}
+
+// keepLeaseAlive extends the job lease while a long run is in progress so other
+// judges don't claim the same submission, and stops the job once a
+// cancellation was requested through the queue.
+func (j judge) keepLeaseAlive(ctx context.Context, leaseOwner string, jobUUID string, cancelJob context.CancelFunc) {
+	ticker := time.NewTicker(j.visibilityTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := j.submissionJobDataAccessor.ExtendLease(ctx, jobUUID, leaseOwner, j.visibilityTimeout)
+			if err != nil {
+				return
+			}
+			if cancelRequested {
+				cancelJob()
+				return
+			}
+		}
+	}
 }
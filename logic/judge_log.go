@@ -0,0 +1,58 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// newJudgeLog starts the full log of judging a submission. It keeps up to
+// the archive limit, so many tests printing up to the run limit each can't
+// exhaust the judge's memory either.
+func (j judge) newJudgeLog() *outputBuffer {
+	return newOutputBuffer(j.archiveLimitInByte, nil)
+}
+
+// writeJudgeLogSection adds the output of one stream of a run to the log.
+func writeJudgeLogSection(log *outputBuffer, name string, output string) {
+	if output == "" {
+		return
+	}
+	fmt.Fprintf(log, "--- %s ---\n%s", name, output)
+	if output[len(output)-1] != '\n' {
+		fmt.Fprintln(log)
+	}
+}
+
+// archiveJudgeLog uploads the full log and returns the ID of its file, or ""
+// when there is nothing to keep. The verdict stands without the log, so a
+// failed upload is only logged.
+func (j judge) archiveJudgeLog(ctx context.Context, submissionUUID string, log *outputBuffer) string {
+	if log == nil || log.buf.Len() == 0 {
+		return ""
+	}
+	content := log.String()
+	if log.exceeded {
+		content += fmt.Sprintf("\n... log truncated at %d bytes\n", j.archiveLimitInByte)
+	}
+	fileID, err := j.judgeLogDataAccessor.UploadJudgeLog(ctx, submissionUUID, content)
+	if err != nil {
+		j.logger.Error("fail to archive judge log", zap.String("submissionUUID", submissionUUID), zap.Error(err))
+		return ""
+	}
+	return fileID
+}
+
+// truncateGradingResult keeps the start of a grading result that is too
+// large to store on the submission.
+func truncateGradingResult(gradingResult string, limit uint64, archived bool) string {
+	if limit == 0 || uint64(len(gradingResult)) <= limit {
+		return gradingResult
+	}
+	note := "\n... truncated"
+	if archived {
+		note += ", download the judge log for the full output"
+	}
+	return gradingResult[:limit] + note
+}
@@ -0,0 +1,431 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"example/server/handlers/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// nativeRunner isolates each run in fresh mount, PID, IPC, UTS and, unless
+// the language opts into networking, network namespaces, limits it with its
+// own cgroup v2 group and rlimits, and pivots it into the language's root
+// filesystem as an unprivileged user without capabilities. It needs no
+// Docker daemon, but the judge has to run as root.
+type nativeRunner struct {
+	logger     *zap.Logger
+	language   string
+	config     runnerConfig
+	limits     sandboxLimits
+	uid        int
+	gid        int
+	cgroupRoot string
+}
+
+func newNativeRunner(logger *zap.Logger, language string, config runnerConfig) (*nativeRunner, error) {
+	if config.Native.RootFS == "" {
+		return nil, fmt.Errorf("native sandbox for %s needs a root_fs", language)
+	}
+	info, err := os.Stat(config.Native.RootFS)
+	if err != nil {
+		return nil, fmt.Errorf("native sandbox root_fs for %s: %w", language, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("native sandbox root_fs %s is not a directory", config.Native.RootFS)
+	}
+	limits, err := parseSandboxLimits(config.Sandbox)
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to load sandbox profile")
+		return nil, err
+	}
+	uid, gid, err := parseSandboxUser(config.Sandbox.User)
+	if err != nil {
+		return nil, err
+	}
+	// The sandbox has no user namespace, so root inside it is root on the
+	// host.
+	if uid <= 0 || gid <= 0 {
+		return nil, fmt.Errorf("native sandbox for %s needs a non-root sandbox user", language)
+	}
+	if config.Sandbox.SeccompProfile != "" {
+		return nil, fmt.Errorf("native sandbox for %s does not support seccomp profiles", language)
+	}
+	if config.PoolSize > 0 {
+		logger.Warn("container pools are only supported by the docker backend, ignoring", zap.String("language", language))
+	}
+
+	n := &nativeRunner{
+		logger:     logger,
+		language:   language,
+		config:     config,
+		limits:     limits,
+		uid:        uid,
+		gid:        gid,
+		cgroupRoot: config.Native.GetCgroupRoot(),
+	}
+	if err := n.setupCgroupRoot(); err != nil {
+		logger.With(zap.Error(err)).Error("failed to set up cgroup for native sandbox")
+		return nil, err
+	}
+	return n, nil
+}
+
+// parseSandboxUser accepts the numeric "uid:gid" form; names can't be
+// resolved without reading the root filesystem's passwd file.
+func parseSandboxUser(user string) (int, int, error) {
+	if user == "" {
+		return -1, -1, nil
+	}
+	uidPart, gidPart, hasGID := strings.Cut(user, ":")
+	uid, err := strconv.Atoi(uidPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("native sandbox user must be numeric uid:gid, got %q", user)
+	}
+	gid := uid
+	if hasGID {
+		gid, err = strconv.Atoi(gidPart)
+		if err != nil {
+			return 0, 0, fmt.Errorf("native sandbox user must be numeric uid:gid, got %q", user)
+		}
+	}
+	return uid, gid, nil
+}
+
+func (n *nativeRunner) setupCgroupRoot() error {
+	if err := os.MkdirAll(n.cgroupRoot, 0755); err != nil {
+		return err
+	}
+	// Child groups can only set limits for controllers enabled here.
+	controllers := "+cpu +cpuset +memory +pids"
+	if err := os.WriteFile(filepath.Join(n.cgroupRoot, "cgroup.subtree_control"), []byte(controllers), 0644); err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers in %s: %w", n.cgroupRoot, err)
+	}
+	return nil
+}
+
+func (n *nativeRunner) Acquire(ctx context.Context) (*Workspace, error) {
+	hostDir, err := createWorkspaceDir(workspaceDirPrefix, n.config.Sandbox)
+	if err != nil {
+		n.logger.Error("fail to make temp directory", zap.Error(err))
+		return nil, err
+	}
+	return &Workspace{HostDir: hostDir}, nil
+}
+
+func (n *nativeRunner) Release(workspace *Workspace) {
+	if workspace != nil {
+		os.RemoveAll(workspace.HostDir)
+	}
+}
+
+func (n *nativeRunner) PoolHealth() *models.ContainerPoolHealth {
+	return nil
+}
+
+// Readiness is nil: the root filesystem was checked when the runner was made.
+func (n *nativeRunner) Readiness() *models.RunnerReadiness {
+	return nil
+}
+
+func (n *nativeRunner) Close(ctx context.Context) {}
+
+func (n *nativeRunner) Run(ctx context.Context, workspace *Workspace, spec RunSpec) (RunResult, error) {
+	cgroupDir := filepath.Join(n.cgroupRoot, uuid.NewString())
+	if err := os.Mkdir(cgroupDir, 0755); err != nil {
+		n.logger.Error("fail to create run cgroup", zap.Error(err))
+		return RunResult{}, err
+	}
+	defer n.removeCgroup(cgroupDir)
+	if err := n.applyCgroupLimits(cgroupDir, spec); err != nil {
+		n.logger.Error("fail to apply run cgroup limits", zap.Error(err))
+		return RunResult{}, err
+	}
+	cgroupFD, err := syscall.Open(cgroupDir, syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer syscall.Close(cgroupFD)
+
+	initSpec, err := json.Marshal(n.getInitSpec(workspace, spec))
+	if err != nil {
+		return RunResult{}, err
+	}
+	// The program's output goes through its own pipes rather than the init
+	// process's stdout and stderr, which package initialisers may write to
+	// before the sandbox is set up.
+	var killOnce sync.Once
+	var cmd *exec.Cmd
+	exceeded := func() {
+		killOnce.Do(func() {
+			n.logger.Warn("run exceeded its output limit, killing native sandbox", zap.String("language", n.language))
+			n.kill(cmd, cgroupDir)
+		})
+	}
+	stdout, err := newOutputPipe(spec.Stdout, spec.OutputLimitInByte, exceeded)
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer stdout.close()
+	stderr, err := newOutputPipe(nil, spec.OutputLimitInByte, exceeded)
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer stderr.close()
+	// Stdin goes through a pipe the judge feeds itself, so waiting for the
+	// program never waits on a stream that another run still holds open.
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer stdinReader.Close()
+	defer stdinWriter.Close()
+	var setupErrBuf bytes.Buffer
+	cmd = exec.Command("/proc/self/exe", sandboxInitArg)
+	cmd.Env = []string{sandboxSpecEnv + "=" + string(initSpec)}
+	cmd.Stderr = &setupErrBuf
+	cmd.ExtraFiles = []*os.File{stdout.writer, stderr.writer}
+	cmd.Stdin = stdinReader
+	cloneFlags := uintptr(syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if n.config.NetworkMode == "" || n.config.NetworkMode == "none" {
+		cloneFlags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  cloneFlags,
+		UseCgroupFD: true,
+		CgroupFD:    cgroupFD,
+		Pdeathsig:   syscall.SIGKILL,
+	}
+
+	startedAt := time.Now()
+	if err := cmd.Start(); err != nil {
+		n.logger.Error("fail to start native sandbox", zap.Error(err))
+		return RunResult{}, err
+	}
+	stdout.start()
+	stderr.start()
+	stdinReader.Close()
+	go func() {
+		if spec.Stdin != nil {
+			io.Copy(stdinWriter, spec.Stdin)
+		}
+		stdinWriter.Close()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var deadline <-chan time.Time
+	if spec.KillAfter > 0 {
+		timer := time.NewTimer(spec.KillAfter)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	killed := false
+	select {
+	case <-done:
+	case <-deadline:
+		n.logger.Warn("run exceeded its wall time limit, killing native sandbox", zap.String("language", n.language))
+		killed = true
+		n.kill(cmd, cgroupDir)
+		<-done
+	case <-ctx.Done():
+		n.kill(cmd, cgroupDir)
+		<-done
+		return RunResult{}, ctx.Err()
+	}
+	wallTime := time.Since(startedAt)
+	stdoutLog := stdout.wait()
+	stderrLog := stderr.wait()
+
+	exitCode := int64(cmd.ProcessState.ExitCode())
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		exitCode = 128 + int64(status.Signal())
+	}
+	if exitCode == sandboxSetupExitCode {
+		if index := strings.Index(setupErrBuf.String(), sandboxSetupErrorPrefix); index >= 0 {
+			return RunResult{}, errors.New(strings.TrimSpace(setupErrBuf.String()[index:]))
+		}
+	}
+
+	usage := resourceUsage{WallTime: wallTime}
+	usage.CPUTime = readCgroupCPUTime(cgroupDir)
+	usage.PeakMemoryInByte = readCgroupUint(filepath.Join(cgroupDir, "memory.peak"))
+	var reports map[string][]byte
+	if spec.ReportsDir != "" {
+		// The workspace is the sandbox's working directory, so the reports
+		// are read in place.
+		reports, err = readReportsDir(filepath.Join(workspace.HostDir, spec.ReportsDir), spec.OutputLimitInByte)
+		if err != nil {
+			n.logger.Warn("fail to read reports", zap.String("language", n.language), zap.Error(err))
+		}
+	}
+	return RunResult{
+		ExitCode:            exitCode,
+		StdOut:              stdoutLog,
+		StdErr:              stderrLog,
+		Usage:               usage,
+		OOMKilled:           readCgroupKeyedValue(filepath.Join(cgroupDir, "memory.events"), "oom_kill") > 0,
+		Killed:              killed,
+		OutputLimitExceeded: stdout.buf.exceeded || stderr.buf.exceeded,
+		Reports:             reports,
+	}, nil
+}
+
+func (n *nativeRunner) getInitSpec(workspace *Workspace, spec RunSpec) nativeSandboxSpec {
+	initSpec := nativeSandboxSpec{
+		RootFS:              n.config.Native.RootFS,
+		HostWorkingDir:      workspace.HostDir,
+		WorkingDir:          n.config.WorkingDir,
+		Mounts:              n.config.Mounts,
+		Command:             spec.Command,
+		ReadOnlyRootFS:      n.config.Sandbox.ReadOnlyRootFS,
+		TmpfsDir:            n.config.Sandbox.GetTmpfsDir(),
+		TmpfsSizeInByte:     n.limits.tmpfsSizeInByte,
+		UID:                 n.uid,
+		GID:                 n.gid,
+		OpenFilesLimit:      n.config.Sandbox.OpenFilesLimit,
+		FileSizeLimitInByte: n.limits.fileSizeLimitInByte,
+		NoNewPrivileges:     n.config.Sandbox.NoNewPrivileges,
+	}
+	if spec.KillAfter > 0 {
+		initSpec.CPUTimeLimitInSecond = uint64(math.Ceil(spec.KillAfter.Seconds()))
+	}
+	return initSpec
+}
+
+func (n *nativeRunner) applyCgroupLimits(cgroupDir string, spec RunSpec) error {
+	limits := map[string]string{"memory.swap.max": "0"}
+	if spec.MemoryLimitInByte > 0 {
+		limits["memory.max"] = strconv.FormatUint(spec.MemoryLimitInByte, 10)
+	}
+	if n.config.Sandbox.PidsLimit > 0 {
+		limits["pids.max"] = strconv.FormatInt(n.config.Sandbox.PidsLimit, 10)
+	}
+	if spec.CPUSet != "" {
+		limits["cpuset.cpus"] = spec.CPUSet
+	}
+	if n.config.CPUQuota > 0 {
+		// Same period Docker uses for its CPU quota.
+		limits["cpu.max"] = fmt.Sprintf("%d 100000", n.config.CPUQuota)
+	}
+	for file, value := range limits {
+		if err := os.WriteFile(filepath.Join(cgroupDir, file), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kill stops every process of the run. Killing the PID namespace's init is
+// enough on its own; cgroup.kill also covers kernels that keep stragglers.
+func (n *nativeRunner) kill(cmd *exec.Cmd, cgroupDir string) {
+	os.WriteFile(filepath.Join(cgroupDir, "cgroup.kill"), []byte("1"), 0644)
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		n.logger.Warn("fail to kill native sandbox", zap.Error(err))
+	}
+}
+
+func (n *nativeRunner) removeCgroup(cgroupDir string) {
+	os.WriteFile(filepath.Join(cgroupDir, "cgroup.kill"), []byte("1"), 0644)
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(cgroupDir); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.logger.Warn("fail to remove run cgroup", zap.String("cgroup", cgroupDir), zap.Error(err))
+}
+
+// outputPipe collects one output stream of a sandboxed program up to the
+// output limit, or streams it to destination when one is given.
+type outputPipe struct {
+	reader      *os.File
+	writer      *os.File
+	destination io.Writer
+	buf         *outputBuffer
+	done        chan struct{}
+}
+
+func newOutputPipe(destination io.Writer, limit uint64, onExceed func()) (*outputPipe, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &outputPipe{reader: reader, writer: writer, buf: newOutputBuffer(limit, onExceed), done: make(chan struct{})}
+	p.destination = p.buf
+	if destination != nil {
+		p.destination = &lenientWriter{writer: destination}
+	}
+	return p, nil
+}
+
+// start begins reading once the child holds its own copy of the writer.
+func (p *outputPipe) start() {
+	p.writer.Close()
+	go func() {
+		defer close(p.done)
+		io.Copy(p.destination, p.reader)
+	}()
+}
+
+// wait returns the output once every process in the sandbox has exited.
+func (p *outputPipe) wait() string {
+	<-p.done
+	return p.buf.String()
+}
+
+func (p *outputPipe) close() {
+	p.writer.Close()
+	p.reader.Close()
+}
+
+func readCgroupCPUTime(cgroupDir string) time.Duration {
+	usageInMicrosecond := readCgroupKeyedValue(filepath.Join(cgroupDir, "cpu.stat"), "usage_usec")
+	return time.Duration(usageInMicrosecond) * time.Microsecond
+}
+
+func readCgroupUint(path string) uint64 {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	return value
+}
+
+// readCgroupKeyedValue reads one "key value" line from files like cpu.stat.
+func readCgroupKeyedValue(path string, key string) uint64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.ParseUint(fields[1], 10, 64)
+			return value
+		}
+	}
+	return 0
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package logic
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+func newNativeRunner(logger *zap.Logger, language string, config runnerConfig) (Runner, error) {
+	return nil, fmt.Errorf("native sandbox for %s is only supported on Linux", language)
+}
+
+// IsSandboxInit is always false where the native sandbox isn't available.
+func IsSandboxInit() bool {
+	return false
+}
+
+func RunSandboxInit() {}
@@ -2,14 +2,17 @@ package logic
 
 import (
 	"context"
+	"example/server/configs"
 	"example/server/db"
 	"example/server/handlers/models"
+	"fmt"
 	"sync"
 	"time"
 
 	"example/server/utils"
 
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.uber.org/zap"
 )
 
@@ -19,12 +22,22 @@ type Problem interface {
 	GetAllProblems(ctx context.Context) (*models.GetProblemListResponse, error)
 	GetAllTestCasesByProblemUUID(ctx context.Context, in *models.GetTestCaseListRequest) (*models.GetTestCaseListResponse, error)
 	DeleteProblem(ctx context.Context, in *models.DeleteProblemRequest) error
+	SetProblemChecker(ctx context.Context, in *models.SetProblemCheckerRequest) error
+	DeleteProblemChecker(ctx context.Context, in *models.DeleteProblemCheckerRequest) error
+	SetProblemInteractor(ctx context.Context, in *models.SetProblemInteractorRequest) error
+	DeleteProblemInteractor(ctx context.Context, in *models.DeleteProblemInteractorRequest) error
+	SetProblemSubtasks(ctx context.Context, in *models.SetProblemSubtasksRequest) error
+	DeleteProblemSubtasks(ctx context.Context, in *models.DeleteProblemSubtasksRequest) error
+	GetLanguages(ctx context.Context, in *models.GetLanguageListRequest) (*models.GetLanguageListResponse, error)
 }
 type problem struct {
 	logger                        *zap.Logger
 	problemDataAccessor           db.ProblemDataAccessor
 	testDataAccessor              db.TestCaseDataAccessor
 	submissionSnippetDataAccessor db.SubmissionSnippetDataAccessor
+	ioTestCaseDataAccessor        db.IOTestCaseDataAccessor
+	problemFixtureDataAccessor    db.ProblemFixtureDataAccessor
+	judgeConfig                   *configs.Judge
 }
 
 func (p problem) deleteAllTestsInProblem(ctx context.Context, testCaseList []db.TestCaseData) error {
@@ -78,6 +91,16 @@ func (p problem) DeleteProblem(ctx context.Context, in *models.DeleteProblemRequ
 		return err
 	}
 
+	err = p.ioTestCaseDataAccessor.DeleteIOTestCasesByProblemUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		return err
+	}
+
+	err = p.problemFixtureDataAccessor.DeleteProblemFixturesByProblemUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		return err
+	}
+
 	p.logger.Info("deleteting")
 	err = p.problemDataAccessor.DeleteProblem(ctx, in.ProblemUUID)
 	if err != nil {
@@ -98,6 +121,15 @@ func (p problem) GetProblemByUUID(ctx context.Context, in *models.GetProblemRequ
 
 func (p problem) CreateProblem(ctx context.Context, in *models.CreateProblemRequest) (*models.CreateProblemResponse, error) {
 	currentTime := utils.FormatTime(time.Now())
+	judgeMode := in.JudgeMode
+	if judgeMode == "" {
+		judgeMode = db.ProblemJudgeModeTestHarness
+	}
+	switch judgeMode {
+	case db.ProblemJudgeModeTestHarness, db.ProblemJudgeModeStandardIO, db.ProblemJudgeModeInteractive:
+	default:
+		return nil, fmt.Errorf("unknown judge mode: %s", judgeMode)
+	}
 
 	problem := db.Problem{
 		UUID:                   uuid.NewString(),
@@ -107,6 +139,7 @@ func (p problem) CreateProblem(ctx context.Context, in *models.CreateProblemRequ
 		AuthorName:             in.AuthorName,
 		TimeLimitInMillisecond: in.TimeLimitInMillisecond,
 		MemoryLimitInByte:      in.MemoryLimitInByte,
+		JudgeMode:              judgeMode,
 		CreatedAt:              currentTime,
 		UpdatedAt:              currentTime,
 		TestCaseList:           []db.TestCaseData{},
@@ -152,6 +185,155 @@ func (p problem) GetAllTestCasesByProblemUUID(ctx context.Context, in *models.Ge
 	return &models.GetTestCaseListResponse{TestCaseList: list}, nil
 }
 
+// SetProblemChecker replaces the exact output comparison of a standard
+// input/output problem with the setter's checker.
+func (p problem) SetProblemChecker(ctx context.Context, in *models.SetProblemCheckerRequest) error {
+	return p.setProblemProgram(ctx, in.ProblemUUID, "checker", db.ProblemJudgeModeStandardIO,
+		db.ProblemProgram{Language: in.Language, Source: in.Source},
+		func(language *configs.Language) bool { return language.Checker != nil })
+}
+
+// DeleteProblemChecker goes back to comparing output exactly.
+func (p problem) DeleteProblemChecker(ctx context.Context, in *models.DeleteProblemCheckerRequest) error {
+	return p.deleteProblemProgram(ctx, in.ProblemUUID, "checker")
+}
+
+// SetProblemInteractor sets the program an interactive problem's
+// submissions talk to.
+func (p problem) SetProblemInteractor(ctx context.Context, in *models.SetProblemInteractorRequest) error {
+	return p.setProblemProgram(ctx, in.ProblemUUID, "interactor", db.ProblemJudgeModeInteractive,
+		db.ProblemProgram{Language: in.Language, Source: in.Source},
+		func(language *configs.Language) bool { return language.Interactor != nil })
+}
+
+func (p problem) DeleteProblemInteractor(ctx context.Context, in *models.DeleteProblemInteractorRequest) error {
+	return p.deleteProblemProgram(ctx, in.ProblemUUID, "interactor")
+}
+
+// SetProblemSubtasks replaces the problem's subtasks. Input/output tests
+// name their subtasks when they are created, so they come first. Submissions
+// judged before keep the score they got.
+func (p problem) SetProblemSubtasks(ctx context.Context, in *models.SetProblemSubtasksRequest) error {
+	problem, err := p.problemDataAccessor.GetProblemByUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		p.logger.Error("fail to get problem by UUID", zap.Any("problemUUID", in.ProblemUUID))
+		return err
+	}
+	ioTestCases, err := p.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		p.logger.Error("fail to get io test cases by problem UUID", zap.String("problemUUID", in.ProblemUUID))
+		return err
+	}
+	if err := validateSubtasks(in.Subtasks, problem.JudgeMode, ioTestCases); err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{
+		"subtasks":  in.Subtasks,
+		"updatedAt": utils.FormatTime(time.Now()),
+	}}
+	if err := p.problemDataAccessor.UpdateProblem(ctx, in.ProblemUUID, update); err != nil {
+		return err
+	}
+	p.logger.Info("set problem subtasks", zap.String("problemUUID", in.ProblemUUID), zap.Int("subtasks", len(in.Subtasks)))
+	return nil
+}
+
+// DeleteProblemSubtasks goes back to scoring submissions all or nothing.
+func (p problem) DeleteProblemSubtasks(ctx context.Context, in *models.DeleteProblemSubtasksRequest) error {
+	update := bson.M{
+		"$unset": bson.M{"subtasks": ""},
+		"$set":   bson.M{"updatedAt": utils.FormatTime(time.Now())},
+	}
+	return p.problemDataAccessor.UpdateProblem(ctx, in.ProblemUUID, update)
+}
+
+// setProblemProgram stores a setter program under field, after checking the
+// problem's judge mode and that its language can run it.
+func (p problem) setProblemProgram(
+	ctx context.Context,
+	problemUUID string,
+	field string,
+	judgeMode string,
+	program db.ProblemProgram,
+	supported func(language *configs.Language) bool,
+) error {
+	problem, err := p.problemDataAccessor.GetProblemByUUID(ctx, problemUUID)
+	if err != nil {
+		p.logger.Error("fail to get problem by UUID", zap.Any("problemUUID", problemUUID))
+		return err
+	}
+	if problem.JudgeMode != judgeMode {
+		return fmt.Errorf("the %s is only used by %s problems", field, judgeMode)
+	}
+	if language := p.judgeConfig.GetLanguage(program.Language); language == nil || !supported(language) {
+		return fmt.Errorf("language %s can't be used for the %s", program.Language, field)
+	}
+	if program.Source == "" {
+		return fmt.Errorf("%s source is empty", field)
+	}
+
+	update := bson.M{"$set": bson.M{
+		field:       program,
+		"updatedAt": utils.FormatTime(time.Now()),
+	}}
+	if err := p.problemDataAccessor.UpdateProblem(ctx, problemUUID, update); err != nil {
+		return err
+	}
+	p.logger.Info("set problem program", zap.String("problemUUID", problemUUID), zap.String("field", field), zap.String("language", program.Language))
+	return nil
+}
+
+func (p problem) deleteProblemProgram(ctx context.Context, problemUUID string, field string) error {
+	update := bson.M{
+		"$unset": bson.M{field: ""},
+		"$set":   bson.M{"updatedAt": utils.FormatTime(time.Now())},
+	}
+	return p.problemDataAccessor.UpdateProblem(ctx, problemUUID, update)
+}
+
+// GetLanguages lists the configured languages and, for a problem, which of
+// them it has a submission snippet and tests for. Standard input/output
+// tests don't depend on the language, so they count for every language.
+func (p problem) GetLanguages(ctx context.Context, in *models.GetLanguageListRequest) (*models.GetLanguageListResponse, error) {
+	snippetLanguages := make(map[string]bool)
+	testLanguages := make(map[string]bool)
+	hasIOTests := false
+	if in.ProblemUUID != "" {
+		problem, err := p.problemDataAccessor.GetProblemByUUID(ctx, in.ProblemUUID)
+		if err != nil {
+			p.logger.Error("fail to get problem by UUID", zap.String("problemUUID", in.ProblemUUID), zap.Error(err))
+			return nil, err
+		}
+		for _, snippet := range problem.SubmissionSnippetList {
+			snippetLanguages[snippet.Language] = true
+		}
+		for _, testCase := range problem.TestCaseList {
+			testLanguages[testCase.Language] = true
+		}
+		if problem.JudgeMode == db.ProblemJudgeModeStandardIO || problem.JudgeMode == db.ProblemJudgeModeInteractive {
+			ioTestCases, err := p.ioTestCaseDataAccessor.GetIOTestCasesByProblemUUID(ctx, in.ProblemUUID)
+			if err != nil {
+				p.logger.Error("fail to get io test cases", zap.String("problemUUID", in.ProblemUUID), zap.Error(err))
+				return nil, err
+			}
+			hasIOTests = len(ioTestCases) > 0
+		}
+	}
+
+	response := &models.GetLanguageListResponse{Languages: []*models.LanguageInfo{}}
+	for _, language := range p.judgeConfig.Languages {
+		response.Languages = append(response.Languages, &models.LanguageInfo{
+			Value:          language.Value,
+			Name:           language.Name,
+			SourceFileName: language.GetSourceFileName(),
+			HasSnippet:     snippetLanguages[language.Value],
+			HasTest:        hasIOTests || testLanguages[language.Value],
+		})
+	}
+	return response, nil
+}
+
 func (p problem) GetAllProblems(ctx context.Context) (*models.GetProblemListResponse, error) {
 
 	listOfProblems, err := p.problemDataAccessor.GetAllProblems(ctx)
@@ -170,11 +352,17 @@ func NewProblemLogic(logger *zap.Logger,
 	problemDataAccessor db.ProblemDataAccessor,
 	testDataAccessor db.TestCaseDataAccessor,
 	submissionSnippetDataAccessor db.SubmissionSnippetDataAccessor,
+	ioTestCaseDataAccessor db.IOTestCaseDataAccessor,
+	problemFixtureDataAccessor db.ProblemFixtureDataAccessor,
+	judgeConfig *configs.Judge,
 ) Problem {
 
 	return &problem{logger: logger,
 		problemDataAccessor:           problemDataAccessor,
 		testDataAccessor:              testDataAccessor,
 		submissionSnippetDataAccessor: submissionSnippetDataAccessor,
+		ioTestCaseDataAccessor:        ioTestCaseDataAccessor,
+		problemFixtureDataAccessor:    problemFixtureDataAccessor,
+		judgeConfig:                   judgeConfig,
 	}
 }
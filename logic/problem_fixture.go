@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"example/server/configs"
+	"example/server/db"
+	"example/server/handlers/models"
+	"example/server/utils"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var ErrFixtureTooLarge = errors.New("fixture is too large")
+
+type ProblemFixture interface {
+	CreateProblemFixture(ctx context.Context, in *models.CreateProblemFixtureRequest) (*models.CreateProblemFixtureResponse, error)
+	GetProblemFixtureList(ctx context.Context, in *models.GetProblemFixtureListRequest) (*models.GetProblemFixtureListResponse, error)
+	DeleteProblemFixture(ctx context.Context, in *models.DeleteProblemFixtureRequest) error
+}
+
+type problemFixture struct {
+	logger                     *zap.Logger
+	problemFixtureDataAccessor db.ProblemFixtureDataAccessor
+	problemDataAccessor        db.ProblemDataAccessor
+	judgeConfig                *configs.Judge
+	maxFixtureSize             uint64
+	maxProblemFixturesSize     uint64
+}
+
+// CreateProblemFixture stores a fixture, replacing the problem's fixture of
+// the same name.
+func (p *problemFixture) CreateProblemFixture(ctx context.Context, in *models.CreateProblemFixtureRequest) (*models.CreateProblemFixtureResponse, error) {
+	if err := validateFileName(in.Name); err != nil {
+		return nil, err
+	}
+	if p.judgeConfig.IsReservedFileName(in.Name) {
+		return nil, fmt.Errorf("%w: %q is already taken", ErrInvalidFileName, in.Name)
+	}
+	content := []byte(in.Content)
+	if in.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(in.Content)
+		if err != nil {
+			return nil, fmt.Errorf("fixture content is not valid base64: %w", err)
+		}
+		content = decoded
+	}
+	if uint64(len(content)) > p.maxFixtureSize {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrFixtureTooLarge, len(content), p.maxFixtureSize)
+	}
+
+	problem, err := p.problemDataAccessor.GetProblemByUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		p.logger.Error("fail to get problem by uuid", zap.String("problemUUID", in.ProblemUUID))
+		return nil, err
+	}
+	otherFixturesSize, err := p.problemFixtureDataAccessor.GetProblemFixturesSize(ctx, problem.UUID, in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(otherFixturesSize)+uint64(len(content)) > p.maxProblemFixturesSize {
+		return nil, fmt.Errorf("%w: the problem's fixtures may take %d bytes in all", ErrFixtureTooLarge, p.maxProblemFixturesSize)
+	}
+
+	fixture := &db.ProblemFixture{
+		UUID:          uuid.NewString(),
+		OfProblemUUID: problem.UUID,
+		Name:          in.Name,
+		Content:       content,
+		SizeInByte:    int64(len(content)),
+		CreatedAt:     utils.FormatTime(time.Now()),
+	}
+	err = p.problemFixtureDataAccessor.SaveProblemFixture(ctx, fixture)
+	if err != nil {
+		p.logger.Error("fail to save problem fixture", zap.String("problemUUID", in.ProblemUUID), zap.Error(err))
+		return nil, err
+	}
+	return &models.CreateProblemFixtureResponse{Fixture: *fixture}, nil
+}
+
+func (p *problemFixture) GetProblemFixtureList(ctx context.Context, in *models.GetProblemFixtureListRequest) (*models.GetProblemFixtureListResponse, error) {
+	fixtures, err := p.problemFixtureDataAccessor.GetProblemFixturesByProblemUUID(ctx, in.ProblemUUID)
+	if err != nil {
+		p.logger.Error("fail to get problem fixtures by problem uuid", zap.String("problemUUID", in.ProblemUUID))
+		return &models.GetProblemFixtureListResponse{}, err
+	}
+	return &models.GetProblemFixtureListResponse{Fixtures: fixtures}, nil
+}
+
+func (p *problemFixture) DeleteProblemFixture(ctx context.Context, in *models.DeleteProblemFixtureRequest) error {
+	return p.problemFixtureDataAccessor.DeleteProblemFixture(ctx, in.UUID)
+}
+
+func NewProblemFixtureLogic(logger *zap.Logger,
+	problemFixtureDataAccessor db.ProblemFixtureDataAccessor,
+	problemDataAccessor db.ProblemDataAccessor,
+	judgeConfig *configs.Judge,
+) (ProblemFixture, error) {
+	maxFixtureSize, err := judgeConfig.Files.GetMaxFixtureSizeInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse max fixture size")
+		return nil, err
+	}
+	maxProblemFixturesSize, err := judgeConfig.Files.GetMaxProblemFixturesSizeInByte()
+	if err != nil {
+		logger.With(zap.Error(err)).Error("failed to parse max problem fixtures size")
+		return nil, err
+	}
+	return &problemFixture{
+		logger:                     logger,
+		problemFixtureDataAccessor: problemFixtureDataAccessor,
+		problemDataAccessor:        problemDataAccessor,
+		judgeConfig:                judgeConfig,
+		maxFixtureSize:             maxFixtureSize,
+		maxProblemFixturesSize:     maxProblemFixturesSize,
+	}, nil
+}
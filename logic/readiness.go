@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"example/server/configs"
+	"example/server/handlers/models"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Readiness states of a runner, and of a language as the worst of its runners.
+const (
+	readinessPulling = "pulling"
+	readinessReady   = "ready"
+	readinessFailed  = "failed"
+)
+
+// readiness tracks whether a runner's image is available. A nil readiness
+// is always ready, for runners that need nothing before their first run.
+type readiness struct {
+	mutex     sync.Mutex
+	mode      string
+	image     string
+	state     string
+	reason    string
+	attempts  int
+	updatedAt time.Time
+}
+
+func newReadiness(mode string, image string) *readiness {
+	return &readiness{mode: mode, image: image, state: readinessPulling, updatedAt: time.Now()}
+}
+
+func (r *readiness) set(state string, reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if state == readinessPulling {
+		r.attempts++
+	}
+	r.state = state
+	r.reason = reason
+	r.updatedAt = time.Now()
+}
+
+func (r *readiness) snapshot() *models.RunnerReadiness {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return &models.RunnerReadiness{
+		Mode:        r.mode,
+		Image:       r.image,
+		State:       r.state,
+		Reason:      r.reason,
+		Attempts:    r.attempts,
+		UpdatedTime: r.updatedAt.UnixMilli(),
+	}
+}
+
+// readinessRank orders states from best to worst.
+var readinessRank = map[string]int{readinessReady: 0, readinessPulling: 1, readinessFailed: 2}
+
+// summarizeReadiness makes a language as ready as its least ready runner.
+func summarizeReadiness(language *models.LanguageReadiness) {
+	language.State = readinessReady
+	for _, runner := range language.Runners {
+		if readinessRank[runner.State] > readinessRank[language.State] {
+			language.State = runner.State
+			language.Reason = runner.Reason
+		}
+	}
+}
+
+var ErrLanguageNotReady = errors.New("language is not ready")
+
+// languageReadiness collects the runners a submission in the language goes
+// through: its compiler and its test harness and standard input/output runs,
+// along with the test library the harness needs, and the runners of checkers
+// and interactors written in it.
+func (j judge) languageReadiness(language configs.Language) *models.LanguageReadiness {
+	readiness := &models.LanguageReadiness{
+		Language: language.Value,
+		Name:     language.Name,
+		Runners:  []*models.RunnerReadiness{},
+	}
+	var runners []*models.RunnerReadiness
+	if compileLogic, ok := j.languageToCompileLogic[language.Value]; ok {
+		runners = append(runners, compileLogic.Readiness())
+	}
+	if compileLogic, ok := j.languageToHarnessCompile[language.Value]; ok {
+		runners = append(runners, compileLogic.Readiness())
+	}
+	if runLogic, ok := j.languageToTestCaseRunLogic[language.Value]; ok {
+		runners = append(runners, runLogic.Readiness(), runLogic.LibraryReadiness())
+	}
+	if runLogic, ok := j.languageToStandardIORunLogic[language.Value]; ok {
+		runners = append(runners, runLogic.Readiness())
+	}
+	if checker, ok := j.languageToChecker[language.Value]; ok {
+		runners = append(runners, checker.readiness()...)
+	}
+	if interactor, ok := j.languageToInteractor[language.Value]; ok {
+		runners = append(runners, interactor.readiness()...)
+	}
+	for _, runner := range runners {
+		if runner != nil {
+			readiness.Runners = append(readiness.Runners, runner)
+		}
+	}
+	summarizeReadiness(readiness)
+	return readiness
+}
+
+// GetLanguageReadiness reports on every configured language.
+func (j judge) GetLanguageReadiness(ctx context.Context) (*models.GetLanguageReadinessResponse, error) {
+	response := &models.GetLanguageReadinessResponse{Languages: []*models.LanguageReadiness{}}
+	for _, language := range j.judgeConfig.Languages {
+		response.Languages = append(response.Languages, j.languageReadiness(language))
+	}
+	return response, nil
+}
+
+// CheckLanguageReadiness rejects submissions for a language whose images
+// failed to pull. Submissions for a language still pulling are accepted and
+// held in the queue until it is ready. Unknown languages pass, judging
+// reports them as unsupported.
+func (j judge) CheckLanguageReadiness(language string) error {
+	configured := j.judgeConfig.GetLanguage(language)
+	if configured == nil {
+		return nil
+	}
+	readiness := j.languageReadiness(*configured)
+	if readiness.State == readinessFailed {
+		return fmt.Errorf("%w: %s", ErrLanguageNotReady, readiness.Reason)
+	}
+	return nil
+}
+
+// unreadyLanguages lists the languages the queue holds back until their
+// images are available.
+func (j judge) unreadyLanguages() []string {
+	var languages []string
+	for _, language := range j.judgeConfig.Languages {
+		if j.languageReadiness(language).State != readinessReady {
+			languages = append(languages, language.Value)
+		}
+	}
+	return languages
+}
@@ -0,0 +1,84 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"go.uber.org/zap"
+)
+
+// resourceUsage is what a single container execution consumed.
+type resourceUsage struct {
+	CPUTime          time.Duration
+	WallTime         time.Duration
+	PeakMemoryInByte uint64
+}
+
+// monitorResourceUsage samples the container's cgroup counters through the
+// stats stream until ctx is cancelled or the container stops. CPU time is
+// cumulative, so the last sample is the total; memory is the highest
+// working set seen, excluding reclaimable page cache like `docker stats` does.
+func (d *dockerRunner) monitorResourceUsage(ctx context.Context, containerID string) <-chan resourceUsage {
+	usageCh := make(chan resourceUsage, 1)
+	go func() {
+		var usage resourceUsage
+		defer func() { usageCh <- usage }()
+
+		stats, err := d.dockerClient.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				d.logger.Warn("fail to stream container stats", zap.String("containerID", containerID), zap.Error(err))
+			}
+			return
+		}
+		defer stats.Body.Close()
+
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var sample types.StatsJSON
+			if err := decoder.Decode(&sample); err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					d.logger.Warn("fail to decode container stats", zap.String("containerID", containerID), zap.Error(err))
+				}
+				return
+			}
+			cpuTime := time.Duration(sample.CPUStats.CPUUsage.TotalUsage)
+			if cpuTime > usage.CPUTime {
+				usage.CPUTime = cpuTime
+			}
+			if memory := workingSetInByte(sample.MemoryStats); memory > usage.PeakMemoryInByte {
+				usage.PeakMemoryInByte = memory
+			}
+		}
+	}()
+	return usageCh
+}
+
+func workingSetInByte(memoryStats types.MemoryStats) uint64 {
+	inactiveFile, ok := memoryStats.Stats["inactive_file"] // cgroup v2
+	if !ok {
+		inactiveFile = memoryStats.Stats["total_inactive_file"] // cgroup v1
+	}
+	if inactiveFile > memoryStats.Usage {
+		return 0
+	}
+	return memoryStats.Usage - inactiveFile
+}
+
+// containerWallTime reads how long the container's process ran from the
+// timestamps Docker records on start and exit.
+func containerWallTime(startedAt string, finishedAt string) time.Duration {
+	started, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return 0
+	}
+	finished, err := time.Parse(time.RFC3339Nano, finishedAt)
+	if err != nil || finished.Before(started) {
+		return 0
+	}
+	return finished.Sub(started)
+}
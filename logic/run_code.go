@@ -0,0 +1,103 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"example/server/db"
+	"example/server/handlers/models"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRunCodeRateLimited   = errors.New("too many runs, try again later")
+	ErrRunCodeInputTooLarge = errors.New("custom input is too large")
+	ErrRunCodeBusy          = errors.New("the judge is busy with this language, try again later")
+)
+
+// RunCode compiles and runs the source once with the given stdin, under the
+// problem's limits and in the same sandbox as standard input/output judging.
+// Nothing is persisted, so it doesn't count as a submission. The run doesn't
+// see the problem's fixtures.
+func (j judge) RunCode(ctx context.Context, username string, request *models.RunCodeRequest) (*models.RunCodeResponse, error) {
+	if uint64(len(request.Stdin)) > j.runCodeMaxInputSize {
+		return nil, ErrRunCodeInputTooLarge
+	}
+	if err := j.CheckSubmissionFiles(request.Content, request.Files); err != nil {
+		return nil, err
+	}
+	if !j.runCodeRateLimiter.allow(username) {
+		return nil, ErrRunCodeRateLimited
+	}
+	problem, err := j.problemDataAccessor.GetProblemByUUID(ctx, request.ProblemUUID)
+	if err != nil {
+		j.logger.Error("fail to get problem by UUID", zap.Error(err), zap.Any("problemUUID", request.ProblemUUID))
+		return nil, err
+	}
+	runLogic := j.languageToStandardIORunLogic[request.Language]
+	if runLogic == nil {
+		return &models.RunCodeResponse{
+			Result:        db.SubmissionResultUnsupportedLanguage,
+			CompileOutput: fmt.Sprintf("language %s does not support running with custom input", request.Language),
+		}, nil
+	}
+	if configured := j.judgeConfig.GetLanguage(request.Language); configured != nil {
+		if readiness := j.languageReadiness(*configured); readiness.State != readinessReady {
+			return nil, fmt.Errorf("%w: %s", ErrLanguageNotReady, readiness.State)
+		}
+	}
+	if !j.languageLimiter.tryAcquire(request.Language) {
+		return nil, ErrRunCodeBusy
+	}
+	defer j.languageLimiter.release(request.Language)
+
+	programDirectory, compileVerdict, err := j.compileSubmission(ctx, &db.Submission{
+		UUID:     "run-" + uuid.NewString(),
+		Language: request.Language,
+		Content:  request.Content,
+		Files:    request.Files,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if compileVerdict != nil {
+		return &models.RunCodeResponse{
+			Result:        compileVerdict.Result,
+			CompileOutput: compileVerdict.GradingResult,
+		}, nil
+	}
+	if programDirectory != "" {
+		defer os.RemoveAll(programDirectory)
+	}
+
+	// The problem's fixtures are left out: they may hold expected outputs
+	// or hidden data, and the caller would get them back in stdout.
+	source := RunSource{Content: request.Content, Files: request.Files}
+	output, err := runLogic.RunWithInput(ctx, source, programDirectory, request.Stdin,
+		formatTimeLimit(problem.TimeLimitInMillisecond), problem.MemoryLimitInByte)
+	if err != nil {
+		return nil, err
+	}
+	j.logger.Info("ran code with custom input",
+		zap.String("username", username),
+		zap.String("problemUUID", request.ProblemUUID),
+		zap.String("language", request.Language))
+	return &models.RunCodeResponse{
+		Result:                classifyRunCodeOutput(output),
+		StdOut:                output.StdOut,
+		StdErr:                output.StdErr,
+		ExitCode:              output.ExitCode,
+		TimeInMillisecond:     runTimeInMillisecond(output),
+		WallTimeInMillisecond: output.WallTimeInMillisecond,
+		MemoryInByte:          output.PeakMemoryInByte,
+	}, nil
+}
+
+// classifyRunCodeOutput judges a run that has no jury answer to compare with.
+func classifyRunCodeOutput(output RunOutput) db.SubmissionResult {
+	testResult, _ := classifyRunFailure(output)
+	return testResult.Result
+}
@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// errReportsTooLarge stops copying reports once they pass the run's output
+// limit; the reports read so far are kept.
+var errReportsTooLarge = errors.New("reports exceed the output limit")
+
+// runReports collects the files of a run's reports directory, keyed by their
+// path within it, up to limit bytes in all. A zero limit keeps everything.
+type runReports struct {
+	files map[string][]byte
+	size  uint64
+	limit uint64
+}
+
+func newRunReports(limit uint64) *runReports {
+	return &runReports{files: make(map[string][]byte), limit: limit}
+}
+
+func (r *runReports) add(name string, reader io.Reader, size int64) error {
+	if r.limit > 0 && r.size+uint64(size) > r.limit {
+		return errReportsTooLarge
+	}
+	content, err := io.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return err
+	}
+	r.files[name] = content
+	r.size += uint64(len(content))
+	return nil
+}
+
+// readReportsArchive reads the tar stream Docker returns when copying a
+// directory out of a container. Entries are named after the directory
+// itself, e.g. "reports/report.xml", so the first part is dropped.
+func readReportsArchive(archive io.Reader, limit uint64) (map[string][]byte, error) {
+	reports := newRunReports(limit)
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return reports.files, nil
+		}
+		if err != nil {
+			return reports.files, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		_, name, found := strings.Cut(path.Clean(header.Name), "/")
+		if !found {
+			continue
+		}
+		if err := reports.add(name, reader, header.Size); err != nil {
+			return reports.files, fmt.Errorf("fail to read report %s: %w", name, err)
+		}
+	}
+}
+
+// readReportsDir reads a reports directory the host can see directly. A
+// missing directory yields no reports.
+func readReportsDir(dir string, limit uint64) (map[string][]byte, error) {
+	reports := newRunReports(limit)
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && filePath == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := reports.add(filepath.ToSlash(name), file, info.Size()); err != nil {
+			return fmt.Errorf("fail to read report %s: %w", name, err)
+		}
+		return nil
+	})
+	return reports.files, err
+}
@@ -0,0 +1,214 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"example/server/configs"
+	"example/server/handlers/models"
+
+	"github.com/docker/docker/client"
+	"github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+// Runner executes commands in an isolated environment with a host directory
+// mounted as the working directory. Compile and test case runs are written
+// against it, so the sandbox backend can be picked per language.
+type Runner interface {
+	// Acquire returns the workspace for the next run; the caller fills its
+	// HostDir and hands it back through Release once the outputs are read.
+	Acquire(ctx context.Context) (*Workspace, error)
+	Run(ctx context.Context, workspace *Workspace, spec RunSpec) (RunResult, error)
+	Release(workspace *Workspace)
+	PoolHealth() *models.ContainerPoolHealth
+	// Readiness reports whether the runner can run yet, or nil when it
+	// needs nothing before its first run.
+	Readiness() *models.RunnerReadiness
+	Close(ctx context.Context)
+}
+
+// Workspace is the host directory a run sees as its working directory.
+type Workspace struct {
+	HostDir string
+	pooled  *pooledContainer
+}
+
+type RunSpec struct {
+	Command []string
+	// Stdin is fed to the command; nil leaves its stdin closed.
+	Stdin io.Reader
+	// Stdout receives the command's output as it is written, in place of
+	// RunResult.StdOut, so that two runs can talk to each other.
+	Stdout io.Writer
+	// KillAfter is the wall clock deadline after which the runner kills the
+	// command itself. Zero waits until ctx is done.
+	KillAfter         time.Duration
+	MemoryLimitInByte uint64
+	CPUSet            string
+	// OutputLimitInByte caps stdout and stderr each. A command that writes
+	// more is killed. Zero keeps all of its output.
+	OutputLimitInByte uint64
+	// ReportsDir, relative to the working directory, is copied out of the
+	// sandbox once the command has ended. Its files count towards
+	// OutputLimitInByte together.
+	ReportsDir string
+}
+
+type RunResult struct {
+	ExitCode  int64
+	StdOut    string
+	StdErr    string
+	Usage     resourceUsage
+	OOMKilled bool
+	Killed    bool
+	// OutputLimitExceeded reports that the command was killed for writing
+	// more than RunSpec.OutputLimitInByte; the output kept stops there.
+	OutputLimitExceeded bool
+	// Reports holds the files of RunSpec.ReportsDir keyed by their path
+	// within it. It is empty when the command didn't write any.
+	Reports map[string][]byte
+}
+
+// lenientWriter keeps accepting output after its destination failed, so a
+// program isn't blocked writing once the process it talks to has exited.
+type lenientWriter struct {
+	writer io.Writer
+	failed bool
+}
+
+func (l *lenientWriter) Write(p []byte) (int, error) {
+	if !l.failed {
+		if _, err := l.writer.Write(p); err != nil {
+			l.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+// outputBuffer keeps the first limit bytes written to it and drops the rest,
+// so a program printing in a loop can't exhaust the judge's memory. It calls
+// onExceed each time a write goes past the limit; a zero limit keeps everything.
+type outputBuffer struct {
+	buf      bytes.Buffer
+	limit    uint64
+	exceeded bool
+	onExceed func()
+}
+
+func newOutputBuffer(limit uint64, onExceed func()) *outputBuffer {
+	return &outputBuffer{limit: limit, onExceed: onExceed}
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	if o.limit == 0 {
+		return o.buf.Write(p)
+	}
+	if room := o.limit - uint64(o.buf.Len()); uint64(len(p)) > room {
+		o.buf.Write(p[:room])
+		o.exceeded = true
+		if o.onExceed != nil {
+			o.onExceed()
+		}
+		return len(p), nil
+	}
+	return o.buf.Write(p)
+}
+
+func (o *outputBuffer) String() string {
+	return o.buf.String()
+}
+
+// runnerMount is an extra host path made visible inside the sandbox.
+type runnerMount struct {
+	HostPath string
+	Path     string
+	ReadOnly bool
+}
+
+// runnerConfig is what both backends need to know about the environment.
+type runnerConfig struct {
+	Image       string
+	WorkingDir  string
+	CPUQuota    int64
+	NetworkMode string
+	Mounts      []runnerMount
+	Sandbox     configs.Sandbox
+	IdleCommand []string
+	PoolSize    int
+	Native      configs.NativeSandbox
+}
+
+// sandboxLimits holds the parts of a sandbox profile that need parsing, so a
+// bad profile fails at startup rather than per run.
+type sandboxLimits struct {
+	tmpfsSizeInByte     uint64
+	fileSizeLimitInByte int64
+}
+
+func parseSandboxLimits(sandbox configs.Sandbox) (sandboxLimits, error) {
+	var limits sandboxLimits
+	if sandbox.TmpfsSize != "" {
+		tmpfsSizeInByte, err := humanize.ParseBytes(sandbox.TmpfsSize)
+		if err != nil {
+			return sandboxLimits{}, fmt.Errorf("failed to parse sandbox tmpfs size: %w", err)
+		}
+		limits.tmpfsSizeInByte = tmpfsSizeInByte
+	}
+	if sandbox.FileSizeLimit != "" {
+		fileSizeLimitInByte, err := humanize.ParseBytes(sandbox.FileSizeLimit)
+		if err != nil {
+			return sandboxLimits{}, fmt.Errorf("failed to parse sandbox file size limit: %w", err)
+		}
+		limits.fileSizeLimitInByte = int64(fileSizeLimitInByte)
+	}
+	return limits, nil
+}
+
+// Host directories are named after the judge instance that made them, so the
+// janitor can tell the leftovers of a crashed judge apart. Pooled workspaces
+// idle for as long as their container waits, so they never count as stale.
+const (
+	workspaceDirPrefix       = "coodbox-run-"
+	pooledWorkspaceDirPrefix = "coodbox-pool-"
+)
+
+// makeWorkspaceDir creates a temp directory owned by this instance.
+func makeWorkspaceDir(prefix string) (string, error) {
+	return os.MkdirTemp("", fmt.Sprintf("%s%s-*", prefix, instanceID))
+}
+
+// createWorkspaceDir makes a fresh host directory for one run.
+func createWorkspaceDir(prefix string, sandbox configs.Sandbox) (string, error) {
+	hostDir, err := makeWorkspaceDir(prefix)
+	if err != nil {
+		return "", err
+	}
+	// A non-root sandbox user still has to write reports and build
+	// artefacts into the work directory. The sticky bit keeps it from
+	// removing or replacing the files the judge wrote there, fixtures
+	// included.
+	if sandbox.User != "" {
+		if err := os.Chmod(hostDir, 0777|os.ModeSticky); err != nil {
+			os.RemoveAll(hostDir)
+			return "", err
+		}
+	}
+	return hostDir, nil
+}
+
+// newRunner builds the runner for the configured backend.
+func newRunner(docker *client.Client, logger *zap.Logger, language string, mode string, backend string, config runnerConfig) (Runner, error) {
+	switch backend {
+	case "", configs.SandboxBackendDocker:
+		return newDockerRunner(docker, logger, language, mode, config)
+	case configs.SandboxBackendNative:
+		return newNativeRunner(logger, language, config)
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q for %s", backend, language)
+	}
+}
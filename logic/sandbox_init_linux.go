@@ -0,0 +1,330 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sandboxInitArg = "__coodbox_sandbox_init"
+	sandboxSpecEnv = "COODBOX_SANDBOX_SPEC"
+	// sandboxSetupExitCode and the error prefix tell a sandbox that failed
+	// to set itself up apart from a program that failed.
+	sandboxSetupExitCode    = 125
+	sandboxSetupErrorPrefix = "sandbox setup failed: "
+
+	sandboxPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	// prSetNoNewPrivs, prCapBSetDrop, prCapAmbient and
+	// prCapAmbientClearAll are from linux/prctl.h.
+	prSetNoNewPrivs      = 38
+	prCapBSetDrop        = 24
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	// linuxCapabilityVersion3 is _LINUX_CAPABILITY_VERSION_3 from
+	// linux/capability.h.
+	linuxCapabilityVersion3 = 0x20080522
+	// sandboxStdoutFD and sandboxStderrFD are the ExtraFiles the native
+	// runner passes for the program's output.
+	sandboxStdoutFD = 3
+	sandboxStderrFD = 4
+	// memoryDeviceMajor is the major number of /dev/null and friends.
+	memoryDeviceMajor = 1
+)
+
+// nativeSandboxSpec is handed from the judge to the sandbox init process.
+type nativeSandboxSpec struct {
+	RootFS               string
+	HostWorkingDir       string
+	WorkingDir           string
+	Mounts               []runnerMount
+	Command              []string
+	ReadOnlyRootFS       bool
+	TmpfsDir             string
+	TmpfsSizeInByte      uint64
+	UID                  int
+	GID                  int
+	OpenFilesLimit       int64
+	FileSizeLimitInByte  int64
+	CPUTimeLimitInSecond uint64
+	NoNewPrivileges      bool
+}
+
+// IsSandboxInit reports whether this process was started by the native
+// runner to set up a sandbox, and must call RunSandboxInit before anything else.
+func IsSandboxInit() bool {
+	return len(os.Args) > 1 && os.Args[1] == sandboxInitArg
+}
+
+// RunSandboxInit runs as PID 1 of the fresh namespaces: it builds the
+// filesystem view, applies rlimits, drops the user and every capability and
+// then replaces itself with the command. It never returns.
+func RunSandboxInit() {
+	err := sandboxInit()
+	fmt.Fprintf(os.Stderr, "%s%v\n", sandboxSetupErrorPrefix, err)
+	os.Exit(sandboxSetupExitCode)
+}
+
+func sandboxInit() error {
+	var spec nativeSandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		return fmt.Errorf("invalid sandbox spec: %w", err)
+	}
+	if len(spec.Command) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	root := spec.RootFS
+
+	// Keep every mount below private to this namespace.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := syscall.Mount(root, root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind root filesystem: %w", err)
+	}
+	if err := bindMount(spec.HostWorkingDir, filepath.Join(root, spec.WorkingDir), false); err != nil {
+		return err
+	}
+	for _, mount := range spec.Mounts {
+		if err := bindMount(mount.HostPath, filepath.Join(root, mount.Path), mount.ReadOnly); err != nil {
+			return err
+		}
+	}
+	if err := mountPseudoFilesystems(root); err != nil {
+		return err
+	}
+	if spec.TmpfsSizeInByte > 0 {
+		tmpfsDir := filepath.Join(root, spec.TmpfsDir)
+		if err := os.MkdirAll(tmpfsDir, 0755); err != nil {
+			return err
+		}
+		options := fmt.Sprintf("size=%d,mode=1777", spec.TmpfsSizeInByte)
+		if err := syscall.Mount("tmpfs", tmpfsDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+			return fmt.Errorf("mount tmpfs: %w", err)
+		}
+	}
+	if spec.ReadOnlyRootFS {
+		if err := syscall.Mount("", root, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("make root filesystem read-only: %w", err)
+		}
+	}
+	lastCapability, err := readLastCapability()
+	if err != nil {
+		return err
+	}
+	if err := pivotRoot(root); err != nil {
+		return err
+	}
+	if err := syscall.Chdir(spec.WorkingDir); err != nil {
+		return fmt.Errorf("chdir: %w", err)
+	}
+	syscall.Sethostname([]byte("sandbox"))
+
+	if err := applyRlimits(spec); err != nil {
+		return err
+	}
+	if spec.UID <= 0 || spec.GID <= 0 {
+		return fmt.Errorf("refusing to run as root")
+	}
+	if err := dropBoundingCapabilities(lastCapability); err != nil {
+		return err
+	}
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(spec.GID); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := syscall.Setuid(spec.UID); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+	if err := clearCapabilities(); err != nil {
+		return err
+	}
+	if spec.NoNewPrivileges {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return fmt.Errorf("set no_new_privs: %w", errno)
+		}
+	}
+
+	os.Setenv("PATH", sandboxPath)
+	program, err := exec.LookPath(spec.Command[0])
+	if err != nil {
+		return err
+	}
+	env := []string{"PATH=" + sandboxPath, "HOME=" + spec.TmpfsDir}
+	if err := redirectProgramOutput(); err != nil {
+		return err
+	}
+	return syscall.Exec(program, spec.Command, env)
+}
+
+// pivotRoot makes root the root filesystem and detaches the host's, which,
+// unlike chroot, leaves no way back to it. Pivoting onto the current
+// directory needs no directory for the old root, so root may be read-only.
+func pivotRoot(root string) error {
+	if err := syscall.Chdir(root); err != nil {
+		return fmt.Errorf("chdir to root filesystem: %w", err)
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach host root filesystem: %w", err)
+	}
+	return syscall.Chdir("/")
+}
+
+// readLastCapability reads the highest capability the kernel knows, while
+// the host's /proc is still reachable.
+func readLastCapability() (int, error) {
+	content, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return 0, fmt.Errorf("read cap_last_cap: %w", err)
+	}
+	lastCapability, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("parse cap_last_cap: %w", err)
+	}
+	return lastCapability, nil
+}
+
+// dropBoundingCapabilities empties the bounding and ambient sets, so nothing
+// the program executes can gain a capability back. It needs CAP_SETPCAP, so
+// it runs before the user is switched.
+func dropBoundingCapabilities(lastCapability int) error {
+	for capability := 0; capability <= lastCapability; capability++ {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapBSetDrop, uintptr(capability), 0); errno != 0 {
+			return fmt.Errorf("drop bounding capability %d: %w", capability, errno)
+		}
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("clear ambient capabilities: %w", errno)
+	}
+	return nil
+}
+
+// clearCapabilities empties the effective, permitted and inheritable sets.
+// Leaving root already clears the first two; this makes sure of it.
+func clearCapabilities() error {
+	header := struct {
+		version uint32
+		pid     int32
+	}{version: linuxCapabilityVersion3}
+	var data [2]struct {
+		effective   uint32
+		permitted   uint32
+		inheritable uint32
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset: %w", errno)
+	}
+	return nil
+}
+
+// redirectProgramOutput points stdout and stderr at the runner's pipes.
+// Errors after this no longer reach the runner as setup failures.
+func redirectProgramOutput() error {
+	if err := syscall.Dup3(sandboxStdoutFD, 1, 0); err != nil {
+		return fmt.Errorf("redirect stdout: %w", err)
+	}
+	if err := syscall.Dup3(sandboxStderrFD, 2, 0); err != nil {
+		return fmt.Errorf("redirect stderr: %w", err)
+	}
+	syscall.Close(sandboxStdoutFD)
+	syscall.Close(sandboxStderrFD)
+	return nil
+}
+
+func bindMount(source string, target string, readOnly bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else {
+		err = createMountTarget(target)
+	}
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", source, err)
+	}
+	if readOnly {
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV)
+		if err := syscall.Mount("", target, "", flags, ""); err != nil {
+			return fmt.Errorf("make %s read-only: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func createMountTarget(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// mountPseudoFilesystems gives the sandbox its own /proc for the new PID
+// namespace and a minimal /dev.
+func mountPseudoFilesystems(root string) error {
+	procDir := filepath.Join(root, "proc")
+	if err := os.MkdirAll(procDir, 0555); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", procDir, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount proc: %w", err)
+	}
+	devDir := filepath.Join(root, "dev")
+	if err := os.MkdirAll(devDir, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", devDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "size=64k,mode=755"); err != nil {
+		return fmt.Errorf("mount dev: %w", err)
+	}
+	// Device nodes are created rather than bound from the host, since the
+	// host's /dev is hidden once the root filesystem is the host's own.
+	devices := map[string]int{"null": 3, "zero": 5, "random": 8, "urandom": 9}
+	for name, minor := range devices {
+		path := filepath.Join(devDir, name)
+		if err := syscall.Mknod(path, syscall.S_IFCHR|0666, memoryDeviceMajor<<8|minor); err != nil {
+			return fmt.Errorf("create /dev/%s: %w", name, err)
+		}
+		if err := os.Chmod(path, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyRlimits(spec nativeSandboxSpec) error {
+	limits := map[int]uint64{syscall.RLIMIT_CORE: 0}
+	if spec.OpenFilesLimit > 0 {
+		limits[syscall.RLIMIT_NOFILE] = uint64(spec.OpenFilesLimit)
+	}
+	if spec.FileSizeLimitInByte > 0 {
+		limits[syscall.RLIMIT_FSIZE] = uint64(spec.FileSizeLimitInByte)
+	}
+	if spec.CPUTimeLimitInSecond > 0 {
+		limits[syscall.RLIMIT_CPU] = spec.CPUTimeLimitInSecond
+	}
+	for resource, value := range limits {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", resource, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package logic
+
+import (
+	"errors"
+	"example/server/db"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	ErrInvalidFileName        = errors.New("invalid file name")
+	ErrSubmissionTooLarge     = errors.New("submission is too large")
+	ErrTooManySubmissionFiles = errors.New("submission has too many files")
+)
+
+// maxFileNameLength bounds the relative path of a fixture or submission file.
+const maxFileNameLength = 128
+
+// fileNameRegex accepts relative paths made of plain names, such as
+// "data/input.txt" or "com/example/Helper.java". No part may start with a
+// dot, which rules out ".." and hidden files.
+var fileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*(/[A-Za-z0-9_][A-Za-z0-9_.\-]*)*$`)
+
+// RunSource is what a run writes into its workspace besides its own files:
+// the program's source with the submission's other files, and the problem's
+// fixtures, which the program can read but not change.
+type RunSource struct {
+	Content  string
+	Files    []db.SubmissionFile
+	Fixtures []db.ProblemFixture
+}
+
+// validateFileName checks that name stays inside the work directory.
+func validateFileName(name string) error {
+	if len(name) > maxFileNameLength || !fileNameRegex.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidFileName, name)
+	}
+	return nil
+}
+
+// CheckSubmissionFiles implements Judge. Besides the limits, it rejects names
+// the judge writes itself, so a file can't replace the main source or the test.
+func (j judge) CheckSubmissionFiles(content string, files []db.SubmissionFile) error {
+	if len(files) > j.judgeConfig.Files.GetMaxSubmissionFiles() {
+		return fmt.Errorf("%w: at most %d files besides the main source", ErrTooManySubmissionFiles, j.judgeConfig.Files.GetMaxSubmissionFiles())
+	}
+	size := uint64(len(content))
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		if err := validateFileName(file.Name); err != nil {
+			return err
+		}
+		if names[file.Name] || j.judgeConfig.IsReservedFileName(file.Name) {
+			return fmt.Errorf("%w: %q is already taken", ErrInvalidFileName, file.Name)
+		}
+		names[file.Name] = true
+		size += uint64(len(file.Content))
+	}
+	if size > j.submissionMaxSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrSubmissionTooLarge, size, j.submissionMaxSize)
+	}
+	return nil
+}
+
+// submissionFileMap lists the submission's other files the way Compile takes them.
+func submissionFileMap(files []db.SubmissionFile) map[string]string {
+	fileMap := make(map[string]string, len(files))
+	for _, file := range files {
+		fileMap[file.Name] = file.Content
+	}
+	return fileMap
+}
+
+// writeWorkspaceFile writes a file under hostDir, creating the directories
+// on its path.
+func writeWorkspaceFile(hostDir string, name string, content []byte, perm os.FileMode) error {
+	filePath := filepath.Join(hostDir, filepath.FromSlash(name))
+	if dir := filepath.Dir(filePath); dir != hostDir {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filePath, content, perm)
+}
+
+// writeFixtures writes the problem's fixtures read-only. The sandbox user
+// doesn't own them, so it can't make them writable again, and the sticky
+// work directory keeps it from unlinking or replacing them. Directories on
+// a fixture's path belong to the judge, so it can't write into those at all.
+// All of this relies on a sandbox user: root in the sandbox owns everything.
+func writeFixtures(hostDir string, fixtures []db.ProblemFixture) error {
+	for _, fixture := range fixtures {
+		if err := writeWorkspaceFile(hostDir, fixture.Name, fixture.Content, 0444); err != nil {
+			return err
+		}
+	}
+	return nil
+}
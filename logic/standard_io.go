@@ -0,0 +1,91 @@
+package logic
+
+import (
+	"example/server/db"
+	"fmt"
+	"strings"
+)
+
+// classifyRunFailure reports whether a run failed before its output could
+// be judged, and the resulting test result. The caller fills in the test name.
+// Only the compile stage reports compile errors: stderr is the program's own,
+// so a run that prints something compiler-like still just failed at runtime.
+func classifyRunFailure(output RunOutput) (db.TestResult, bool) {
+	switch {
+	case output.OutputLimitExceeded:
+		return db.TestResult{Result: db.SubmissionResultOutputLimitExceeded}, true
+	case output.MemoryLimitExceeded:
+		return db.TestResult{Result: db.SubmissionResultMemoryLimitExceed}, true
+	case output.TimeLimitExceeded:
+		return db.TestResult{Result: db.SubmissionResultTimeLimitExceeded}, true
+	case output.ExitCode != 0:
+		return db.TestResult{
+			Result:  db.SubmissionResultRuntimeError,
+			Message: truncateTestMessage(fmt.Sprintf("exit code %d: %s", output.ExitCode, strings.TrimSpace(output.StdErr))),
+		}, true
+	}
+	return db.TestResult{Result: db.SubmissionResultOK}, false
+}
+
+// compareWithAnswer judges a successful run against the jury answer exactly.
+func compareWithAnswer(output RunOutput, answer string) db.TestResult {
+	if ok, message := compareOutput(answer, output.StdOut); !ok {
+		return db.TestResult{Result: db.SubmissionResultWrongAnswer, Message: truncateTestMessage(message)}
+	}
+	return db.TestResult{Result: db.SubmissionResultOK}
+}
+
+// compareOutput compares line by line, ignoring trailing whitespace on each
+// line and trailing blank lines, like most online judges do by default.
+func compareOutput(expected string, actual string) (bool, string) {
+	expectedLines := normalizeOutputLines(expected)
+	actualLines := normalizeOutputLines(actual)
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		if i >= len(expectedLines) {
+			return false, fmt.Sprintf("line %d: expected end of output, found %q", i+1, actualLines[i])
+		}
+		if i >= len(actualLines) {
+			return false, fmt.Sprintf("line %d: expected %q, found end of output", i+1, expectedLines[i])
+		}
+		if expectedLines[i] != actualLines[i] {
+			return false, fmt.Sprintf("line %d: expected %q, found %q", i+1, expectedLines[i], actualLines[i])
+		}
+	}
+	return true, ""
+}
+
+func normalizeOutputLines(output string) []string {
+	lines := strings.Split(output, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func describeResult(result db.SubmissionResult) string {
+	switch result {
+	case db.SubmissionResultOK:
+		return "OK"
+	case db.SubmissionResultCompileError:
+		return "Compile Error"
+	case db.SubmissionResultRuntimeError:
+		return "Runtime Error"
+	case db.SubmissionResultTimeLimitExceeded:
+		return "Time Limit Exceeded"
+	case db.SubmissionResultMemoryLimitExceed:
+		return "Memory Limit Exceeded"
+	case db.SubmissionResultWrongAnswer:
+		return "Wrong Answer"
+	case db.SubmissionResultUnsupportedLanguage:
+		return "Unsupported Language"
+	case db.SubmissionResultOutputLimitExceeded:
+		return "Output Limit Exceeded"
+	case db.SubmissionResultInternalError:
+		return "Internal Error"
+	default:
+		return "Unknown"
+	}
+}
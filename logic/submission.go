@@ -2,6 +2,9 @@ package logic
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -19,14 +22,27 @@ type Submission interface {
 	CreateSubmission(ctx context.Context, in *models.CreateSubmissionRequest) (*models.CreateSubmissionResponse, error)
 	DeleteSubmission(ctx context.Context, in *models.DeleteSubmissionRequest) error
 	UpdateSubmission(ctx context.Context, in *models.UpdateSubmissionRequest) error
-	GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string) (*models.GetSubmissionListResponse, error)
+	GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string, showHiddenTests bool) (*models.GetSubmissionListResponse, error)
+	RejudgeSubmissions(ctx context.Context, requestedBy string, in *models.RejudgeRequest) (*models.RejudgeResponse, error)
+	CancelSubmission(ctx context.Context, in *models.CancelSubmissionRequest) (*models.CancelSubmissionResponse, error)
+	// GetSubmissionLog opens the full judge log of the submission's latest
+	// verdict. The caller closes it.
+	GetSubmissionLog(ctx context.Context, in *models.GetSubmissionLogRequest) (io.ReadCloser, error)
 }
 
+var (
+	ErrSubmissionNotFound       = errors.New("submission not found")
+	ErrSubmissionNotOwned       = errors.New("only the author or an admin can cancel a submission")
+	ErrSubmissionNotCancellable = errors.New("submission is not queued or running")
+	ErrSubmissionLogNotFound    = errors.New("submission has no judge log")
+)
+
 type submission struct {
 	db                     *mongo.Client
 	logger                 *zap.Logger
 	judge                  Judge
 	submissionDataAccessor db.SubmissionDataAccessor
+	judgeLogDataAccessor   db.JudgeLogDataAccessor
 }
 
 // CreateSubmission implements Submission.
@@ -36,6 +52,9 @@ func (s *submission) GetSubmission(ctx context.Context, in *models.GetSubmission
 		s.logger.Error("fail to create submission database", zap.Error(err))
 		return &models.GetSubmissionResponse{}, err
 	}
+	if !in.ShowHiddenTests {
+		redactHiddenTests(submissionRes)
+	}
 	return &models.GetSubmissionResponse{Submission: *submissionRes}, nil
 }
 
@@ -45,27 +64,57 @@ func (s *submission) CreateSubmission(ctx context.Context, in *models.CreateSubm
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	if err := s.judge.CheckLanguageReadiness(strings.ToLower(in.Language)); err != nil {
+		return nil, err
+	}
+	if err := s.judge.CheckSubmissionFiles(in.Content, in.Files); err != nil {
+		return nil, err
+	}
+
 	var UUID = uuid.NewString()
 	submission := &db.Submission{
 		UUID:              UUID,
 		ProblemUUID:       in.ProblemUUID,
 		AuthorAccountUUID: in.AuthorAccountUUID,
 		Content:           in.Content,
+		Files:             in.Files,
 		Language:          strings.ToLower(in.Language),
 		Status:            db.SubmissionStatusSubmitted,
 		GradingResult:     "",
 		CreatedTime:       time.Now().UnixMilli(),
 	}
+	// A language the judge doesn't know ends right away instead of going
+	// through the queue.
+	supported := s.judge.IsLanguageSupported(submission.Language)
+	if !supported {
+		submission.Status = db.SubmissionStatusFinished
+		submission.Result = db.SubmissionResultUnsupportedLanguage
+		submission.GradingResult = fmt.Sprintf("language %s is not supported", submission.Language)
+		submission.JudgedTime = submission.CreatedTime
+	}
 	err := s.submissionDataAccessor.CreateSubmission(ctx, submission)
 	if err != nil {
 		return nil, err
 	}
-	time.Sleep(1 * time.Second)
-	s.judge.ScheduleJudgeLocalSubmission(UUID)
+	if !supported {
+		return &models.CreateSubmissionResponse{Submission: *submission}, nil
+	}
+	err = s.judge.ScheduleJudgeLocalSubmission(ctx, UUID, submission.Language)
+	if err != nil {
+		// Don't leave a submission behind that no job will ever judge. The
+		// request's deadline may be what failed the enqueue, so the update
+		// gets its own.
+		updateCtx, cancelUpdate := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancelUpdate()
+		if updateErr := s.submissionDataAccessor.UpdateSubmissionByUUID(updateCtx, UUID, internalErrorUpdate()); updateErr != nil {
+			s.logger.Error("fail to finish submission that could not be enqueued", zap.String("submissionUUID", UUID), zap.Error(updateErr))
+		}
+		return nil, err
+	}
 	return &models.CreateSubmissionResponse{Submission: *submission}, nil
 }
 
-func (s *submission) GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string) (*models.GetSubmissionListResponse, error) {
+func (s *submission) GetSubmissionsByProblemAndAuthor(ctx context.Context, problemUUID, authorAccountUUID string, showHiddenTests bool) (*models.GetSubmissionListResponse, error) {
 	s.logger.Info("Getting submissions by problem and author",
 		zap.String("problemUUID", problemUUID),
 		zap.String("authorAccountUUID", authorAccountUUID))
@@ -79,10 +128,124 @@ func (s *submission) GetSubmissionsByProblemAndAuthor(ctx context.Context, probl
 			zap.Error(err))
 		return nil, err
 	}
+	if !showHiddenTests {
+		for _, submission := range submissions {
+			redactHiddenTests(submission)
+		}
+	}
 	response.Submissions = submissions
 	return &response, nil
 }
 
+// RejudgeSubmissions queues finished submissions to be judged again at low
+// priority. Each rejudged submission keeps the verdict it replaces.
+func (s *submission) RejudgeSubmissions(ctx context.Context, requestedBy string, in *models.RejudgeRequest) (*models.RejudgeResponse, error) {
+	s.logger.Info("Rejudging submissions",
+		zap.String("submissionUUID", in.SubmissionUUID),
+		zap.String("problemUUID", in.ProblemUUID),
+		zap.String("language", in.Language),
+		zap.String("requestedBy", requestedBy))
+
+	var submissions []*db.Submission
+	switch {
+	case in.SubmissionUUID != "" && (in.ProblemUUID != "" || in.Language != ""):
+		return nil, fmt.Errorf("rejudge either a submission or a problem, not both")
+	case in.SubmissionUUID != "":
+		submission, err := s.submissionDataAccessor.GetSubmissionByUUID(ctx, in.SubmissionUUID)
+		if err != nil {
+			return nil, err
+		}
+		if submission.UUID == "" {
+			return nil, fmt.Errorf("no submission found with UUID: %s", in.SubmissionUUID)
+		}
+		submissions = []*db.Submission{submission}
+	case in.ProblemUUID != "":
+		var err error
+		submissions, err = s.submissionDataAccessor.GetSubmissionsByProblem(ctx, in.ProblemUUID, strings.ToLower(in.Language))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("missing submission or problem UUID")
+	}
+
+	response := &models.RejudgeResponse{QueuedSubmissionUUIDs: []string{}, SkippedSubmissionUUIDs: []string{}}
+	for _, submission := range submissions {
+		if submission.Status != db.SubmissionStatusFinished {
+			response.SkippedSubmissionUUIDs = append(response.SkippedSubmissionUUIDs, submission.UUID)
+			continue
+		}
+		// A rejudge already queued or running judges against the current
+		// tests too; another would only add a duplicate history entry.
+		active, err := s.judge.HasActiveJob(ctx, submission.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			response.SkippedSubmissionUUIDs = append(response.SkippedSubmissionUUIDs, submission.UUID)
+			continue
+		}
+		err = s.judge.ScheduleRejudgeSubmission(ctx, submission.UUID, submission.Language, requestedBy)
+		if err != nil {
+			return nil, err
+		}
+		response.QueuedSubmissionUUIDs = append(response.QueuedSubmissionUUIDs, submission.UUID)
+	}
+	return response, nil
+}
+
+// CancelSubmission takes a submission out of the queue, or stops it if it is
+// being judged.
+func (s *submission) CancelSubmission(ctx context.Context, in *models.CancelSubmissionRequest) (*models.CancelSubmissionResponse, error) {
+	s.logger.Info("Cancelling submission", zap.String("submissionUUID", in.SubmissionUUID))
+	submission, err := s.submissionDataAccessor.GetSubmissionByUUID(ctx, in.SubmissionUUID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.UUID == "" {
+		return nil, ErrSubmissionNotFound
+	}
+	if !in.IsAdmin && submission.AuthorAccountUUID != in.AccountUUID {
+		return nil, ErrSubmissionNotOwned
+	}
+
+	removed, running, err := s.judge.CancelSubmission(ctx, submission.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if removed == 0 && running == 0 {
+		return nil, ErrSubmissionNotCancellable
+	}
+	if running > 0 || submission.Status == db.SubmissionStatusFinished {
+		// A running job marks the submission itself once stopped, and a
+		// queued rejudge leaves the finished verdict as it was.
+		return &models.CancelSubmissionResponse{Status: submission.Status}, nil
+	}
+	err = s.submissionDataAccessor.UpdateSubmissionByUUID(ctx, submission.UUID, map[string]any{"status": db.SubmissionStatusCancelled})
+	if err != nil {
+		return nil, err
+	}
+	return &models.CancelSubmissionResponse{Status: db.SubmissionStatusCancelled}, nil
+}
+
+func (s *submission) GetSubmissionLog(ctx context.Context, in *models.GetSubmissionLogRequest) (io.ReadCloser, error) {
+	submission, err := s.submissionDataAccessor.GetSubmissionByUUID(ctx, in.SubmissionUUID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.UUID == "" {
+		return nil, ErrSubmissionNotFound
+	}
+	if submission.LogFileID == "" {
+		return nil, ErrSubmissionLogNotFound
+	}
+	log, err := s.judgeLogDataAccessor.OpenJudgeLog(ctx, submission.LogFileID)
+	if errors.Is(err, db.ErrJudgeLogNotFound) {
+		return nil, ErrSubmissionLogNotFound
+	}
+	return log, err
+}
+
 // CreateSubmission implements Submission.
 func (s *submission) UpdateSubmission(ctx context.Context, in *models.UpdateSubmissionRequest) error {
 	panic("unimplemented")
@@ -93,6 +256,6 @@ func (s *submission) DeleteSubmission(ctx context.Context, in *models.DeleteSubm
 	panic("unimplemented")
 }
 
-func NewSubmissionLogic(j Judge, logger *zap.Logger, client *mongo.Client, submissionDataAccessor db.SubmissionDataAccessor) (s Submission) {
-	return &submission{db: client, judge: j, logger: logger, submissionDataAccessor: submissionDataAccessor}
+func NewSubmissionLogic(j Judge, logger *zap.Logger, client *mongo.Client, submissionDataAccessor db.SubmissionDataAccessor, judgeLogDataAccessor db.JudgeLogDataAccessor) (s Submission) {
+	return &submission{db: client, judge: j, logger: logger, submissionDataAccessor: submissionDataAccessor, judgeLogDataAccessor: judgeLogDataAccessor}
 }
@@ -0,0 +1,137 @@
+package logic
+
+import (
+	"errors"
+	"example/server/db"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidSubtasks = errors.New("invalid subtasks")
+
+// defaultMaxScore is what a problem without subtasks awards for an accepted
+// submission.
+const defaultMaxScore = 100
+
+// validateSubtasks checks a problem's subtasks. Only test harness subtasks
+// list their tests; the input/output tests of the other judge modes name
+// their subtasks, which must all exist and each get at least one test.
+func validateSubtasks(subtasks []db.Subtask, judgeMode string, ioTestCases []db.IOTestCase) error {
+	names := make(map[string]bool, len(subtasks))
+	for _, subtask := range subtasks {
+		if subtask.Name == "" {
+			return fmt.Errorf("%w: a subtask has no name", ErrInvalidSubtasks)
+		}
+		if names[subtask.Name] {
+			return fmt.Errorf("%w: subtask %q is defined twice", ErrInvalidSubtasks, subtask.Name)
+		}
+		names[subtask.Name] = true
+		if subtask.Points < 0 {
+			return fmt.Errorf("%w: subtask %q has negative points", ErrInvalidSubtasks, subtask.Name)
+		}
+		switch {
+		case judgeMode == db.ProblemJudgeModeStandardIO || judgeMode == db.ProblemJudgeModeInteractive:
+			if len(subtask.Tests) > 0 {
+				return fmt.Errorf("%w: the tests of %s problems name their subtasks themselves", ErrInvalidSubtasks, judgeMode)
+			}
+		case len(subtask.Tests) == 0:
+			return fmt.Errorf("%w: subtask %q has no tests", ErrInvalidSubtasks, subtask.Name)
+		}
+	}
+	if judgeMode != db.ProblemJudgeModeStandardIO && judgeMode != db.ProblemJudgeModeInteractive {
+		return nil
+	}
+	testCounts := make(map[string]int, len(subtasks))
+	for _, ioTestCase := range ioTestCases {
+		for _, name := range ioTestCase.Subtasks {
+			if !names[name] {
+				return fmt.Errorf("%w: test #%d names subtask %q, which isn't defined", ErrInvalidSubtasks, ioTestCase.Order, name)
+			}
+			testCounts[name]++
+		}
+	}
+	for _, subtask := range subtasks {
+		if testCounts[subtask.Name] == 0 {
+			return fmt.Errorf("%w: subtask %q has no tests", ErrInvalidSubtasks, subtask.Name)
+		}
+	}
+	return nil
+}
+
+// assignHarnessSubtasks puts each test method of a harness run in the
+// subtasks that list it.
+func assignHarnessSubtasks(testResults []db.TestResult, subtasks []db.Subtask) {
+	for i := range testResults {
+		for _, subtask := range subtasks {
+			if matchesTestName(subtask.Tests, testResults[i].Name) {
+				testResults[i].Subtasks = append(testResults[i].Subtasks, subtask.Name)
+			}
+		}
+	}
+}
+
+// scoreSubmission awards each subtask whose tests all passed. A test that
+// never reported, such as one listed by a harness subtask that a killed run
+// cut short, counts as failed, and so does a subtask left without tests.
+// Without subtasks the submission gets defaultMaxScore when it was accepted.
+func scoreSubmission(subtasks []db.Subtask, result db.SubmissionResult, testResults []db.TestResult) (int64, int64, []db.SubtaskScore) {
+	if len(subtasks) == 0 {
+		if result == db.SubmissionResultOK {
+			return defaultMaxScore, defaultMaxScore, nil
+		}
+		return 0, defaultMaxScore, nil
+	}
+
+	var score, maxScore int64
+	subtaskScores := make([]db.SubtaskScore, 0, len(subtasks))
+	for _, subtask := range subtasks {
+		var subtaskTests []db.TestResult
+		for _, testResult := range testResults {
+			if slices.Contains(testResult.Subtasks, subtask.Name) {
+				subtaskTests = append(subtaskTests, testResult)
+			}
+		}
+		subtaskScore := db.SubtaskScore{Name: subtask.Name, Points: subtask.Points, Result: worstTestResult(subtaskTests)}
+		if subtaskScore.Result == db.SubmissionResultOK && hasMissingTests(subtask, subtaskTests) {
+			subtaskScore.Result = missingTestResult(result)
+		}
+		if subtaskScore.Result == db.SubmissionResultOK {
+			subtaskScore.Score = subtask.Points
+		}
+		score += subtaskScore.Score
+		maxScore += subtask.Points
+		subtaskScores = append(subtaskScores, subtaskScore)
+	}
+	return score, maxScore, subtaskScores
+}
+
+// hasMissingTests reports whether the subtask lacks a result for any of its
+// tests: one of those a harness subtask lists, or any test at all.
+func hasMissingTests(subtask db.Subtask, subtaskTests []db.TestResult) bool {
+	if len(subtaskTests) == 0 {
+		return true
+	}
+	for _, name := range subtask.Tests {
+		found := false
+		for _, testResult := range subtaskTests {
+			if matchesTestName([]string{name}, testResult.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
+
+// missingTestResult is the verdict of a test without a result: that of the
+// run which cut it short, or a runtime error when the run looked fine, as a
+// harness that skipped the test or doesn't have it.
+func missingTestResult(result db.SubmissionResult) db.SubmissionResult {
+	if result == db.SubmissionResultOK {
+		return db.SubmissionResultRuntimeError
+	}
+	return result
+}
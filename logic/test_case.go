@@ -85,6 +85,11 @@ func (t testCase) CreateTestCase(ctx context.Context, in *models.CreateTestCaseR
 		TestFileContent: in.Content,
 		Language:        in.Language,
 		CreatedAt:       currentTime,
+		TestVisibility: db.TestVisibility{
+			IsHidden:    in.IsHidden,
+			HiddenTests: in.HiddenTests,
+			SampleTests: in.SampleTests,
+		},
 	}
 
 	err = t.testCaseDataAccessor.CreateTestCase(ctx, &testCase)
@@ -68,6 +68,7 @@ func (t *testCaseAndSubmissionSnippet) addTestCaseToDatabase(
 	ofProblemUUID string,
 	codeTest string,
 	language string,
+	visibility db.TestVisibility,
 	currentTime string) error {
 
 	testCase := &db.TestCase{
@@ -76,6 +77,7 @@ func (t *testCaseAndSubmissionSnippet) addTestCaseToDatabase(
 		TestFileContent: codeTest,
 		Language:        language,
 		CreatedAt:       currentTime,
+		TestVisibility:  visibility,
 	}
 	err := t.testCaseDataAccessor.CreateTestCase(ctx, testCase)
 	if err != nil {
@@ -158,7 +160,8 @@ func (t *testCaseAndSubmissionSnippet) CreateTestCaseAndSubmissionSnippet(ctx co
 
 	go func() {
 		defer wg.Done()
-		testCaseErr = t.addTestCaseToDatabase(ctx, newTestCaseUUID, in.OfProblemUUID, in.CodeTest, in.Language, currentTime)
+		visibility := db.TestVisibility{IsHidden: in.IsHidden, HiddenTests: in.HiddenTests, SampleTests: in.SampleTests}
+		testCaseErr = t.addTestCaseToDatabase(ctx, newTestCaseUUID, in.OfProblemUUID, in.CodeTest, in.Language, visibility, currentTime)
 	}()
 
 	wg.Wait()
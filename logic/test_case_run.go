@@ -1,156 +1,351 @@
 package logic
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"example/server/configs"
+	"example/server/db"
+	"example/server/handlers/models"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 	"go.uber.org/zap"
 )
 
 type RunOutput struct {
 	ReturnLog           string
+	TestResults         []db.TestResult
+	TestSummary         *db.TestSummary
+	ExitCode            int64
 	TimeLimitExceeded   bool
 	MemoryLimitExceeded bool
+	OutputLimitExceeded bool
 	StdOut              string
 	StdErr              string
+	// Resource usage measured from the container, zero when unavailable.
+	CPUTimeInMillisecond  int64
+	WallTimeInMillisecond int64
+	PeakMemoryInByte      uint64
+	// Reports are the files the run left in its reports directory.
+	Reports map[string][]byte
+	// ExpectsTestResults is set when the harness should report per-test
+	// results, so a run without any didn't get to run the tests.
+	ExpectsTestResults bool
 }
 
+// wallTimeKillSlack is how long past the limit plus grace the judge waits for
+// the command template's timeout before killing the container itself.
+const wallTimeKillSlack = time.Second
+
+// timeoutExitCode is what coreutils' timeout returns when it had to kill the command.
+const timeoutExitCode = 124
+
+// Run modes label what a run logic is used for, e.g. in pool health reports.
+const (
+	runModeTestHarness = db.ProblemJudgeModeTestHarness
+	runModeStandardIO  = db.ProblemJudgeModeStandardIO
+	runModeChecker     = "checker"
+	runModeInteractor  = "interactor"
+	// runModeTestLibrary labels the readiness of a test harness library.
+	runModeTestLibrary = "test_library"
+)
+
+// sigkillExitCode is the exit status of a process killed with SIGKILL.
+const sigkillExitCode = 137
+
 type TestCaseRun interface {
 	Run(ctx context.Context,
 		testCodeSnippet string,
-		submissionCodeSnippet string,
+		source RunSource,
+		programDirectory string,
+		timeLimitInSecond string,
+		memoryLimitInByte uint64) (RunOutput, error)
+	RunWithInput(ctx context.Context,
+		source RunSource,
+		programDirectory string,
+		input string,
+		timeLimitInSecond string,
+		memoryLimitInByte uint64) (RunOutput, error)
+	RunWithFiles(ctx context.Context,
+		source RunSource,
+		programDirectory string,
+		files map[string]string,
 		timeLimitInSecond string,
 		memoryLimitInByte uint64) (RunOutput, error)
+	RunInteractive(ctx context.Context,
+		source RunSource,
+		programDirectory string,
+		files map[string]string,
+		stdin io.Reader,
+		stdout io.Writer,
+		timeLimitInSecond string,
+		memoryLimitInByte uint64) (RunOutput, error)
+	PoolHealth() *models.ContainerPoolHealth
+	Readiness() *models.RunnerReadiness
+	// LibraryReadiness reports whether the test library is in the
+	// dependency cache, or nil when the run needs none.
+	LibraryReadiness() *models.RunnerReadiness
+	Close(ctx context.Context)
 }
 
 type testCaseRun struct {
-	dockerClient      *client.Client
-	logger            *zap.Logger
-	language          string
-	testCaseRunConfig *configs.TestCaseRun
+	logger             *zap.Logger
+	language           string
+	testCaseRunConfig  *configs.TestCaseRun
+	dependencyCacheDir string
+	timeLimitGrace     time.Duration
+	cpuSetPool         *cpuSetPool
+	outputLimitInByte  uint64
+	runner             Runner
+	library            *readiness
+	stopFetching       context.CancelFunc
 }
 
-// func (t testCaseRun) setupWorkingDir(workingDir string) error {
-// 	if strings.ToLower(t.language) == "java" {
-// 		if t.testCaseRunConfig.DownloadTestUrl != nil && t.testCaseRunConfig.TestLibraryName != nil {
-// 			junitURL := "https://repo1.maven.org/maven2/org/junit/platform/junit-platform-console-standalone/1.7.0/junit-platform-console-standalone-1.7.0.jar"
-// 			junitPath := filepath.Join(workingDir, "junit-platform-console-standalone-1.7.0.jar")
-// 			if err := t.downloadFile(junitURL, junitPath); err != nil {
-// 				t.logger.Error("fail to download test file in setupWorkingDir")
-// 				return fmt.Errorf("fail to download test file in setupWorkingDir")
-// 			}
-// 		}
-// 	} else if strings.ToLower(t.language) == "python" {
-// 		return nil
-// 	}
-// 	return nil
-// }
-
-func (t testCaseRun) Run(ctx context.Context, testCodeSnippet string, submissionCodeSnippet string, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
-	hostWorkingDir, err := os.MkdirTemp("", "")
+// testLibraryMountPath is where the dependency cache is mounted, read-only,
+// inside run containers.
+const testLibraryMountPath = "/opt/judge-libs"
+
+func (t testCaseRun) Run(ctx context.Context, testCodeSnippet string, source RunSource, programDirectory string, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
+	workspace, err := t.prepareWorkspace(ctx, source, programDirectory)
 	if err != nil {
-		t.logger.Error("fail to make temp directory", zap.Error(err))
 		return RunOutput{}, err
 	}
-	codeFile, err := t.createTempCodeFile(ctx, hostWorkingDir, t.testCaseRunConfig.CodeFileName, submissionCodeSnippet)
+	defer t.runner.Release(workspace)
+	_, err = t.createTempCodeFile(ctx, workspace.HostDir, t.testCaseRunConfig.TestFileName, testCodeSnippet)
 	if err != nil {
-		t.logger.Error("fail to create temporary code file", zap.Error(err))
+		t.logger.Error("fail to create temporary test file", zap.Error(err))
 		return RunOutput{}, err
 	}
-	testFile, err := t.createTempCodeFile(ctx, hostWorkingDir, t.testCaseRunConfig.TestFileName, testCodeSnippet)
+	if err := t.createReportsDir(workspace.HostDir); err != nil {
+		t.logger.Error("fail to create reports directory", zap.Error(err))
+		return RunOutput{}, err
+	}
+
+	output, err := t.execute(ctx, workspace, nil, nil, timeLimitInSecond, memoryLimitInByte)
 	if err != nil {
-		t.logger.Error("fail to create temporary test file", zap.Error(err))
 		return RunOutput{}, err
 	}
-	workingDir := t.getWorkingDir()
-	// err = t.setupWorkingDir(workingDir)
-	// if err != nil {
-	// 	return RunOutput{}, err
-	// }
-	resp, err := t.createContainer(ctx,
-		workingDir,
-		codeFile,
-		testFile,
-		timeLimitInSecond,
-		memoryLimitInByte,
-		t.testCaseRunConfig.Image,
-		t.testCaseRunConfig.CommandTemplate,
-		t.testCaseRunConfig.CPUQuota,
-	)
+	output.ExpectsTestResults = t.testCaseRunConfig.ResultFormat != ""
+	output.TestResults, output.TestSummary, err = t.collectTestResults(output)
+	if err != nil {
+		t.logger.Warn("fail to collect test results", zap.Error(err))
+	}
+	return output, nil
+}
+
+// RunWithInput runs the submission once with input piped to its stdin, for
+// problems judged by comparing standard output.
+func (t testCaseRun) RunWithInput(ctx context.Context, source RunSource, programDirectory string, input string, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
+	workspace, err := t.prepareWorkspace(ctx, source, programDirectory)
 	if err != nil {
-		t.logger.Error("fail to create Container", zap.Any("err", err))
 		return RunOutput{}, err
 	}
+	defer t.runner.Release(workspace)
+	return t.execute(ctx, workspace, strings.NewReader(input), nil, timeLimitInSecond, memoryLimitInByte)
+}
+
+// RunWithFiles runs the program once with extra files written next to it,
+// such as the input, output and answer a checker reads.
+func (t testCaseRun) RunWithFiles(ctx context.Context, source RunSource, programDirectory string, files map[string]string, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
+	return t.RunInteractive(ctx, source, programDirectory, files, nil, nil, timeLimitInSecond, memoryLimitInByte)
+}
 
-	if err := t.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		t.logger.Error("fail to start the container", zap.Any("containerID", resp.ID))
+// RunInteractive is RunWithFiles with the program's stdin and stdout
+// connected to the given streams while it runs.
+func (t testCaseRun) RunInteractive(ctx context.Context, source RunSource, programDirectory string, files map[string]string, stdin io.Reader, stdout io.Writer, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
+	workspace, err := t.prepareWorkspace(ctx, source, programDirectory)
+	if err != nil {
 		return RunOutput{}, err
 	}
+	defer t.runner.Release(workspace)
+	for fileName, content := range files {
+		if _, err := t.createTempCodeFile(ctx, workspace.HostDir, fileName, content); err != nil {
+			return RunOutput{}, err
+		}
+	}
+	return t.execute(ctx, workspace, stdin, stdout, timeLimitInSecond, memoryLimitInByte)
+}
 
-	defer func() {
-		err = t.dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+// PoolHealth reports on the warm container pool, or nil when pooling is off.
+func (t testCaseRun) PoolHealth() *models.ContainerPoolHealth {
+	return t.runner.PoolHealth()
+}
+
+// Readiness reports whether the runner's image is available.
+func (t testCaseRun) Readiness() *models.RunnerReadiness {
+	return t.runner.Readiness()
+}
+
+func (t testCaseRun) LibraryReadiness() *models.RunnerReadiness {
+	return t.library.snapshot()
+}
+
+// Close releases what the runner holds between runs.
+func (t testCaseRun) Close(ctx context.Context) {
+	if t.stopFetching != nil {
+		t.stopFetching()
+	}
+	t.runner.Close(ctx)
+}
+
+// prepareWorkspace fills the workspace for one run. Compiled languages pass
+// the compile stage's output directory, which is copied so every run starts
+// from the same artefacts; interpreted languages get the sources written
+// directly. Fixtures are written last, read-only, in both cases.
+func (t testCaseRun) prepareWorkspace(ctx context.Context, source RunSource, programDirectory string) (*Workspace, error) {
+	workspace, err := t.runner.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.writeSource(ctx, workspace.HostDir, source, programDirectory); err != nil {
+		t.runner.Release(workspace)
+		return nil, err
+	}
+	if err := writeFixtures(workspace.HostDir, source.Fixtures); err != nil {
+		t.logger.Error("fail to write fixtures", zap.Error(err))
+		t.runner.Release(workspace)
+		return nil, err
+	}
+	return workspace, nil
+}
+
+func (t testCaseRun) writeSource(ctx context.Context, hostWorkingDir string, source RunSource, programDirectory string) error {
+	if programDirectory != "" {
+		err := copyDirectory(programDirectory, hostWorkingDir)
 		if err != nil {
-			t.logger.With(zap.Error(err)).Error("failed to remove run test case container")
+			t.logger.Error("fail to copy compiled program", zap.String("programDirectory", programDirectory), zap.Error(err))
 		}
-	}()
-
-	statusCh, errCh := t.dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errCh:
-		return RunOutput{StdErr: "container channel response with error"}, err
-	case <-statusCh:
-		out, err := t.dockerClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
-		if err != nil {
-			t.logger.Error("Failed to get container logs", zap.Error(err))
-			return RunOutput{}, fmt.Errorf("failed to get container logs: %w", err)
+		return err
+	}
+	_, err := t.createTempCodeFile(ctx, hostWorkingDir, t.testCaseRunConfig.CodeFileName, source.Content)
+	if err != nil {
+		t.logger.Error("fail to create temporary code file", zap.Error(err))
+		return err
+	}
+	for _, file := range source.Files {
+		if err := writeWorkspaceFile(hostWorkingDir, file.Name, []byte(file.Content), 0644); err != nil {
+			t.logger.Error("fail to write submission file", zap.String("fileName", file.Name), zap.Error(err))
+			return err
 		}
-		defer out.Close() // Make sure to close the log output
+	}
+	return nil
+}
 
-		if out == nil {
-			t.logger.Error("Container log output is nil")
-			return RunOutput{}, errors.New("container log output is nil")
+func copyDirectory(sourceDir string, destinationDir string) error {
+	return filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-
-		var stdoutBuf, stderrBuf bytes.Buffer
-		_, err = stdcopy.StdCopy(&stdoutBuf, &stderrBuf, out)
+		relativePath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		destinationPath := filepath.Join(destinationDir, relativePath)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(destinationPath, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		source, err := os.Open(path)
 		if err != nil {
-			t.logger.Error("Failed to copy container logs", zap.Error(err))
-			return RunOutput{}, fmt.Errorf("failed to copy container logs: %w", err)
+			return err
 		}
+		defer source.Close()
+		destination, err := os.OpenFile(destinationPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer destination.Close()
+		_, err = io.Copy(destination, source)
+		return err
+	})
+}
+
+func (t testCaseRun) execute(ctx context.Context, workspace *Workspace, stdin io.Reader, stdout io.Writer, timeLimitInSecond string, memoryLimitInByte uint64) (RunOutput, error) {
+	timeLimit, err := time.ParseDuration(timeLimitInSecond)
+	if err != nil {
+		t.logger.Warn("fail to parse time limit, only the command template enforces it", zap.String("timeLimit", timeLimitInSecond), zap.Error(err))
+		timeLimit = 0
+	}
+	cpuSet, err := t.cpuSetPool.acquire(ctx)
+	if err != nil {
+		return RunOutput{}, err
+	}
+	defer t.cpuSetPool.release(cpuSet)
 
-		stdoutLog := stdoutBuf.String()
-		stderrLog := stderrBuf.String()
+	spec := RunSpec{
+		Command:           t.getContainerCommand(t.testCaseRunConfig.CommandTemplate, timeLimitInSecond, t.testCaseRunConfig.TestFileName),
+		Stdin:             stdin,
+		Stdout:            stdout,
+		MemoryLimitInByte: memoryLimitInByte,
+		CPUSet:            cpuSet,
+		OutputLimitInByte: t.outputLimitInByte,
+		ReportsDir:        t.testCaseRunConfig.ReportsDir,
+	}
+	// The command template's timeout normally stops the program first; the
+	// runner only steps in once the limit plus grace and some slack has passed.
+	if timeLimit > 0 {
+		spec.KillAfter = timeLimit + t.timeLimitGrace + wallTimeKillSlack
+	}
+	result, err := t.runner.Run(ctx, workspace, spec)
+	if err != nil {
+		return RunOutput{}, err
+	}
 
-		if stdoutLog == "" && stderrLog == "" {
-			t.logger.Warn("Container logs are empty")
-			return RunOutput{}, errors.New("container logs are empty")
-		}
+	// Empty output is a legitimate (wrong) answer when judging stdout, but
+	// a harness run always prints something.
+	if stdin == nil && result.StdOut == "" && result.StdErr == "" && result.ExitCode == 0 && !result.OOMKilled {
+		t.logger.Warn("Container logs are empty")
+		return RunOutput{}, errors.New("container logs are empty")
+	}
 
-		t.logger.Info("Container logs retrieved",
-			zap.String("stdout", stdoutLog),
-			zap.String("stderr", stderrLog))
+	usage := result.Usage
+	t.logger.Info("Container logs retrieved",
+		zap.String("stdout", result.StdOut),
+		zap.String("stderr", result.StdErr),
+		zap.Duration("cpuTime", usage.CPUTime),
+		zap.Duration("wallTime", usage.WallTime),
+		zap.Uint64("peakMemoryInByte", usage.PeakMemoryInByte))
 
-		returnLog, err := t.handleReturnLog(stderrLog, stdoutLog)
-		if err != nil {
-			return RunOutput{}, err
-		}
-		return RunOutput{
-			ReturnLog: returnLog,
-		}, nil
+	returnLog, err := t.handleReturnLog(result.StdErr, result.StdOut)
+	if err != nil {
+		return RunOutput{}, err
+	}
+	return RunOutput{
+		ReturnLog:             returnLog,
+		ExitCode:              result.ExitCode,
+		TimeLimitExceeded:     result.Killed || result.ExitCode == timeoutExitCode || t.exceedsTimeLimit(usage, timeLimit),
+		MemoryLimitExceeded:   result.OOMKilled || (memoryLimitInByte > 0 && usage.PeakMemoryInByte >= memoryLimitInByte),
+		OutputLimitExceeded:   result.OutputLimitExceeded,
+		StdOut:                result.StdOut,
+		StdErr:                result.StdErr,
+		CPUTimeInMillisecond:  usage.CPUTime.Milliseconds(),
+		WallTimeInMillisecond: usage.WallTime.Milliseconds(),
+		PeakMemoryInByte:      usage.PeakMemoryInByte,
+		Reports:               result.Reports,
+	}, nil
+}
+
+// exceedsTimeLimit judges the measured usage. CPU time is held to the limit
+// plus grace, which covers runtime start-up and in-container harness builds;
+// wall time gets the same allowance so a sleeping program is still caught.
+func (t testCaseRun) exceedsTimeLimit(usage resourceUsage, timeLimit time.Duration) bool {
+	if timeLimit <= 0 {
+		return false
 	}
+	return usage.CPUTime > timeLimit+t.timeLimitGrace || usage.WallTime > timeLimit+t.timeLimitGrace
 }
 
 func (t testCaseRun) handleReturnLog(stderrLog string, stdoutLog string) (returnLog string, err error) {
@@ -168,38 +363,38 @@ func (t testCaseRun) handleReturnLog(stderrLog string, stdoutLog string) (return
 	}
 }
 
-func (t testCaseRun) createContainer(
-	ctx context.Context,
-	workingDir string,
-	codeFile *os.File,
-	testFile *os.File,
-	timeOutOfContainerInSecond string,
-	memoryLimitInByte uint64,
-	image string,
-	commandTemplate []string,
-	CPUquota int64,
-) (container.CreateResponse, error) {
-	programFileDirectory, codePath := filepath.Split(codeFile.Name())
-	_, testPath := filepath.Split(testFile.Name())
-	filepath.Join(workingDir, codePath)
-	filepath.Join(workingDir, testPath)
-	t.logger.Info(workingDir)
-	t.logger.Info("CodeFile: " + codeFile.Name())
-	t.logger.Info("TestFile: " + testFile.Name())
-	resp, err := t.dockerClient.ContainerCreate(ctx, &container.Config{
-		Image:      image,
-		Cmd:        t.getContainerCommand(commandTemplate, timeOutOfContainerInSecond, t.testCaseRunConfig.TestFileName),
-		WorkingDir: workingDir,
-	}, &container.HostConfig{
-		Binds: []string{fmt.Sprintf("%s:%s", programFileDirectory, workingDir)},
-		Resources: container.Resources{
-			CPUQuota: CPUquota,
-			Memory:   int64(memoryLimitInByte)}, /// 256 * 1024 * 1024 = 256 MB
-		NetworkMode: "host"}, nil, nil, "")
-	if err != nil {
-		return container.CreateResponse{}, err
+// createReportsDir makes the reports directory up front, since harnesses such
+// as utest.h write their report into it without creating it.
+func (t testCaseRun) createReportsDir(hostWorkingDir string) error {
+	if t.testCaseRunConfig.ReportsDir == "" {
+		return nil
 	}
-	return resp, nil
+	reportsDir := filepath.Join(hostWorkingDir, t.testCaseRunConfig.ReportsDir)
+	if err := os.MkdirAll(reportsDir, 0777); err != nil {
+		return err
+	}
+	return os.Chmod(reportsDir, 0777)
+}
+
+// collectTestResults reads per-test outcomes in the format the language's
+// harness produces. JUnit XML reports are copied out of the sandbox by the
+// runner; the other formats are what the harness prints.
+func (t testCaseRun) collectTestResults(output RunOutput) ([]db.TestResult, *db.TestSummary, error) {
+	var results []db.TestResult
+	switch t.testCaseRunConfig.ResultFormat {
+	case configs.ResultFormatJUnitXML:
+		return parseJUnitReports(output.Reports)
+	case configs.ResultFormatUnittest:
+		results = parseUnittestOutput(output.StdErr + "\n" + output.StdOut)
+	case configs.ResultFormatGoTest:
+		results = parseGoTestOutput(output.StdOut + "\n" + output.StdErr)
+	case configs.ResultFormatRustTest:
+		results = parseRustTestOutput(output.StdOut + "\n" + output.StdErr)
+	default:
+		return nil, nil, nil
+	}
+	results = withAssertionValues(results)
+	return results, summarizeTestResults(results), nil
 }
 
 func (t testCaseRun) createTempCodeFile(ctx context.Context, hostWorkingDir string, sourceFileName string, content string) (*os.File, error) {
@@ -223,33 +418,98 @@ func (t testCaseRun) getWorkingDir() string {
 }
 
 func (t testCaseRun) getContainerCommand(commandList []string, timeLimitInSecond string, testFileName string) []string {
+	programFileName := t.testCaseRunConfig.ProgramFileName
+	if programFileName == "" {
+		programFileName = t.testCaseRunConfig.CodeFileName
+	}
+	replacer := strings.NewReplacer(
+		"$TIME_LIMIT", timeLimitInSecond,
+		"$TEST_FILE", testFileName,
+		"$MAIN_FILE", t.testCaseRunConfig.CodeFileName,
+		"$SOURCE", t.testCaseRunConfig.CodeFileName,
+		"$PROGRAM", programFileName,
+		"$TEST_LIBRARY", t.getTestLibraryPath(),
+		"$INPUT_FILE", checkerInputFileName,
+		"$OUTPUT_FILE", checkerOutputFileName,
+		"$ANSWER_FILE", checkerAnswerFileName,
+	)
 	command := make([]string, len(commandList))
 	for i := range commandList {
-		if commandList[i] == "$TIME_LIMIT" {
-			command[i] = timeLimitInSecond
-		} else if commandList[i] == "$TEST_FILE" {
-			command[i] = testFileName
-		} else if commandList[i] == "$MAIN_FILE" {
-			t.logger.Info("code file is" + (t.testCaseRunConfig.CodeFileName))
-			command[i] = t.testCaseRunConfig.CodeFileName
-		} else {
-			command[i] = commandList[i]
-		}
+		command[i] = replacer.Replace(commandList[i])
 	}
 	t.logger.Info("log cmd", zap.Any("command", command))
 	return command
 }
 
-func (t testCaseRun) pullImage() error {
-	t.logger.Info("pulling test case run image")
-	_, err := t.dockerClient.ImagePull(context.Background(), t.testCaseRunConfig.Image, image.PullOptions{})
-	if err != nil {
-		t.logger.With(zap.Error(err)).Error("failed to pull test case run image")
+func (t testCaseRun) getTestLibraryPath() string {
+	if t.testCaseRunConfig.TestLibraryName == nil {
+		return ""
+	}
+	return testLibraryMountPath + "/" + *t.testCaseRunConfig.TestLibraryName
+}
+
+// ensureTestLibrary puts the test harness library into the dependency cache,
+// so run containers never need network access to fetch it. A library bundled
+// with the judge is written on every start so it always matches the binary;
+// others are downloaded once.
+func (t testCaseRun) ensureTestLibrary() error {
+	if t.testCaseRunConfig.TestLibraryName == nil {
+		return nil
+	}
+	bundled, isBundled := bundledTestLibrary(*t.testCaseRunConfig.TestLibraryName)
+	if !isBundled && t.testCaseRunConfig.DownloadTestUrl == nil {
+		return nil
+	}
+	if t.dependencyCacheDir == "" {
+		return fmt.Errorf("no dependency cache directory configured for %s", t.language)
+	}
+	libraryPath := filepath.Join(t.dependencyCacheDir, *t.testCaseRunConfig.TestLibraryName)
+	if err := os.MkdirAll(t.dependencyCacheDir, 0755); err != nil {
+		t.logger.With(zap.Error(err)).Error("failed to create dependency cache directory")
 		return err
 	}
+	downloadPath := libraryPath + ".download"
+	if isBundled {
+		if err := os.WriteFile(downloadPath, bundled, 0644); err != nil {
+			t.logger.With(zap.Error(err)).Error("failed to write bundled test library")
+			return err
+		}
+		return os.Rename(downloadPath, libraryPath)
+	}
+	if _, err := os.Stat(libraryPath); err == nil {
+		return nil
+	}
 
-	t.logger.Info("pulled test case run image successfully")
-	return nil
+	t.logger.Info("downloading test library", zap.String("language", t.language), zap.String("url", *t.testCaseRunConfig.DownloadTestUrl))
+	if err := t.downloadFile(*t.testCaseRunConfig.DownloadTestUrl, downloadPath); err != nil {
+		t.logger.With(zap.Error(err)).Error("failed to download test library")
+		os.Remove(downloadPath)
+		return err
+	}
+	return os.Rename(downloadPath, libraryPath)
+}
+
+// fetchTestLibrary retries ensureTestLibrary the way images are pulled, so a
+// language whose library can't be fetched reports as failed instead of
+// blaming its contestants for the missing library.
+func (t testCaseRun) fetchTestLibrary(ctx context.Context) {
+	backoff := imagePullInitialBackoff
+	for {
+		t.library.set(readinessPulling, "")
+		err := t.ensureTestLibrary()
+		if err == nil {
+			t.library.set(readinessReady, "")
+			return
+		}
+		t.library.set(readinessFailed, err.Error())
+		t.logger.Warn("retrying test library fetch", zap.String("language", t.language), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, imagePullMaxBackoff)
+	}
 }
 
 func (t testCaseRun) downloadFile(url, filepath string) error {
@@ -265,6 +525,9 @@ func (t testCaseRun) downloadFile(url, filepath string) error {
 			continue
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+		}
 
 		out, err := os.Create(filepath)
 		if err != nil {
@@ -281,15 +544,49 @@ func (t testCaseRun) downloadFile(url, filepath string) error {
 	return fmt.Errorf("failed to download file after %d attempts", maxRetries)
 }
 
-func NewTestCaseRunLogic(docker *client.Client, logger *zap.Logger, language string, testCaseRunConfig *configs.TestCaseRun) (TestCaseRun, error) {
+// getMounts lists the extra host paths a run needs, currently the test library cache.
+func (t testCaseRun) getMounts() []runnerMount {
+	if t.testCaseRunConfig.TestLibraryName == nil {
+		return nil
+	}
+	return getDependencyCacheMounts(t.dependencyCacheDir)
+}
 
-	t := &testCaseRun{dockerClient: docker, logger: logger, language: language, testCaseRunConfig: testCaseRunConfig}
+func NewTestCaseRunLogic(docker *client.Client, logger *zap.Logger, language string, mode string, testCaseRunConfig *configs.TestCaseRun, dependencyCacheDir string, cpuSetPool *cpuSetPool, outputLimitInByte uint64) (TestCaseRun, error) {
+
+	t := &testCaseRun{logger: logger, language: language, testCaseRunConfig: testCaseRunConfig, dependencyCacheDir: dependencyCacheDir, cpuSetPool: cpuSetPool, outputLimitInByte: outputLimitInByte}
 	if testCaseRunConfig == nil {
 		t.logger.Error("fail to find test case run config")
 		return t, nil
 	}
-	go func() {
-		t.pullImage()
-	}()
+	if testCaseRunConfig.TimeLimitGrace != "" {
+		timeLimitGrace, err := time.ParseDuration(testCaseRunConfig.TimeLimitGrace)
+		if err != nil {
+			t.logger.With(zap.Error(err)).Error("failed to parse time limit grace")
+			return nil, err
+		}
+		t.timeLimitGrace = timeLimitGrace
+	}
+	runner, err := newRunner(docker, logger, language, mode, testCaseRunConfig.Backend, runnerConfig{
+		Image:       testCaseRunConfig.Image,
+		WorkingDir:  t.getWorkingDir(),
+		CPUQuota:    testCaseRunConfig.CPUQuota,
+		NetworkMode: testCaseRunConfig.NetworkMode,
+		Mounts:      t.getMounts(),
+		Sandbox:     testCaseRunConfig.Sandbox,
+		IdleCommand: testCaseRunConfig.IdleCommand,
+		PoolSize:    testCaseRunConfig.PoolSize,
+		Native:      testCaseRunConfig.Native,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.runner = runner
+	if testCaseRunConfig.TestLibraryName != nil {
+		t.library = newReadiness(runModeTestLibrary, *testCaseRunConfig.TestLibraryName)
+		var fetchCtx context.Context
+		fetchCtx, t.stopFetching = context.WithCancel(context.Background())
+		go t.fetchTestLibrary(fetchCtx)
+	}
 	return t, nil
 }
@@ -0,0 +1,141 @@
+"""Runs a unittest test file and writes a JUnit XML report of it.
+
+The judge reads verdicts from the report rather than from the console, so
+the report has to exist whatever happens to the tests. Only the standard
+library is used, so any Python image can run it.
+
+Usage: python junit_unittest.py --output reports/report.xml test.py
+"""
+
+import argparse
+import importlib
+import os
+import sys
+import time
+import traceback
+import unittest
+import xml.etree.ElementTree as ElementTree
+
+
+class JUnitResult(unittest.TextTestResult):
+    """Keeps every outcome, with its duration, next to the usual console log."""
+
+    def __init__(self, *args, **kwargs):
+        super().__init__(*args, **kwargs)
+        self.cases = []
+        self._started_at = {}
+
+    def startTest(self, test):
+        self._started_at[test.id()] = time.perf_counter()
+        super().startTest(test)
+
+    def _record(self, test, kind=None, err=None, reason=None):
+        started_at = self._started_at.get(test.id(), time.perf_counter())
+        problem = None
+        if err is not None:
+            # unittest's own formatting leaves its frames out of the traceback.
+            problem = (str(err[1]), err[0].__name__, self._exc_info_to_string(err, test))
+        elif kind is not None:
+            problem = (reason, "AssertionError", reason)
+        self.cases.append((test.id(), time.perf_counter() - started_at, kind, problem))
+
+    def addSuccess(self, test):
+        super().addSuccess(test)
+        self._record(test)
+
+    def addFailure(self, test, err):
+        super().addFailure(test, err)
+        self._record(test, "failure", err)
+
+    def addError(self, test, err):
+        super().addError(test, err)
+        self._record(test, "error", err)
+
+    def addSkip(self, test, reason):
+        super().addSkip(test, reason)
+        self._record(test, "skipped", reason=reason)
+
+    def addExpectedFailure(self, test, err):
+        super().addExpectedFailure(test, err)
+        self._record(test)
+
+    def addUnexpectedSuccess(self, test):
+        super().addUnexpectedSuccess(test)
+        self._record(test, "failure", reason="unexpected success")
+
+    def addSubTest(self, test, subtest, err):
+        super().addSubTest(test, subtest, err)
+        if err is not None:
+            kind = "failure" if issubclass(err[0], test.failureException) else "error"
+            self._record(subtest, kind, err)
+
+
+def module_name(test_file):
+    name, _ = os.path.splitext(os.path.normpath(test_file))
+    return name.replace(os.sep, ".")
+
+
+def add_case(suite, test_id, seconds, kind, problem):
+    class_name, _, name = test_id.rpartition(".")
+    case = ElementTree.SubElement(
+        suite, "testcase", classname=class_name, name=name, time="%.3f" % seconds
+    )
+    if kind is None:
+        return
+    message, type_name, body = problem
+    if kind == "skipped":
+        ElementTree.SubElement(case, "skipped", message=message)
+        return
+    element = ElementTree.SubElement(case, kind, message=message, type=type_name)
+    element.text = body
+
+
+def write_report(path, suite_name, cases):
+    suite = ElementTree.Element("testsuite", name=suite_name)
+    counts = {"failure": 0, "error": 0, "skipped": 0}
+    total_seconds = 0.0
+    for test_id, seconds, kind, problem in cases:
+        add_case(suite, test_id, seconds, kind, problem)
+        if kind in counts:
+            counts[kind] += 1
+        total_seconds += seconds
+    suite.set("tests", str(len(cases)))
+    suite.set("failures", str(counts["failure"]))
+    suite.set("errors", str(counts["error"]))
+    suite.set("skipped", str(counts["skipped"]))
+    suite.set("time", "%.3f" % total_seconds)
+    os.makedirs(os.path.dirname(path) or ".", exist_ok=True)
+    ElementTree.ElementTree(suite).write(path, encoding="utf-8", xml_declaration=True)
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--output", required=True)
+    parser.add_argument("test_file")
+    args = parser.parse_args()
+
+    # The script lives apart from the sources, so their directory has to be
+    # put on the path by hand.
+    sys.path.insert(0, os.getcwd())
+    name = module_name(args.test_file)
+    try:
+        module = importlib.import_module(name)
+    except BaseException:
+        # A file that fails to import, e.g. with a SyntaxError, is reported as
+        # a single errored test so the report names the error type.
+        exc_type, exc_value, _ = sys.exc_info()
+        body = traceback.format_exc()
+        sys.stderr.write(body)
+        problem = (str(exc_value), exc_type.__name__, body)
+        write_report(args.output, name, [(name, 0.0, "error", problem)])
+        return 1
+
+    tests = unittest.defaultTestLoader.loadTestsFromModule(module)
+    runner = unittest.TextTestRunner(resultclass=JUnitResult, verbosity=2)
+    result = runner.run(tests)
+    write_report(args.output, name, result.cases)
+    return 0 if result.wasSuccessful() else 1
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,22 @@
+package logic
+
+import (
+	"embed"
+	"path"
+)
+
+// bundledTestLibraries are harness helpers shipped with the judge instead of
+// downloaded, such as the runner that makes Python's unittest write JUnit XML.
+//
+//go:embed test_libraries
+var bundledTestLibraries embed.FS
+
+// bundledTestLibrary returns the content of the bundled library of the given
+// name, if there is one.
+func bundledTestLibrary(name string) ([]byte, bool) {
+	content, err := bundledTestLibraries.ReadFile(path.Join("test_libraries", name))
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
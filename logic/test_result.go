@@ -0,0 +1,395 @@
+package logic
+
+import (
+	"encoding/xml"
+	"example/server/db"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxTestResultMessageLength = 1024
+
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+	// TestCases holds tests outside of any suite, as Node's reporter writes
+	// them.
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestSuite may nest further suites, one per describe block in Node.
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Suites    []junitTestSuite `xml:"testsuite"`
+	TestCases []junitTestCase  `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnitReports reads every JUnit XML report a run wrote, such as those
+// of the JUnit console launcher, pytest's --junitxml or the judge's unittest
+// runner. Reports are read in name order so results keep a stable order.
+func parseJUnitReports(reports map[string][]byte) ([]db.TestResult, *db.TestSummary, error) {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		if strings.HasSuffix(name, ".xml") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	sort.Strings(names)
+
+	var (
+		results []db.TestResult
+		summary db.TestSummary
+	)
+	for _, name := range names {
+		reportResults, err := parseJUnitReport(reports[name], &summary)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to parse report %s: %w", name, err)
+		}
+		results = append(results, reportResults...)
+	}
+	return results, &summary, nil
+}
+
+// parseJUnitReport accepts both a single <testsuite> root and a <testsuites> wrapper.
+func parseJUnitReport(content []byte, summary *db.TestSummary) ([]db.TestResult, error) {
+	var suites junitTestSuites
+	if err := xml.Unmarshal(content, &suites); err != nil || (len(suites.Suites) == 0 && len(suites.TestCases) == 0) {
+		var suite junitTestSuite
+		if err := xml.Unmarshal(content, &suite); err != nil {
+			return nil, err
+		}
+		suites.Suites = []junitTestSuite{suite}
+	}
+
+	results := junitTestResults(suites.TestCases, summary)
+	for _, suite := range suites.Suites {
+		results = append(results, junitSuiteResults(suite, summary)...)
+	}
+	return results, nil
+}
+
+func junitSuiteResults(suite junitTestSuite, summary *db.TestSummary) []db.TestResult {
+	results := junitTestResults(suite.TestCases, summary)
+	for _, nested := range suite.Suites {
+		results = append(results, junitSuiteResults(nested, summary)...)
+	}
+	return results
+}
+
+// junitTestResults turns test cases into results, counting them in summary.
+// Skipped tests are counted but get no result.
+func junitTestResults(testCases []junitTestCase, summary *db.TestSummary) []db.TestResult {
+	var results []db.TestResult
+	for _, testCase := range testCases {
+		summary.Total++
+		if testCase.Skipped != nil {
+			summary.Skipped++
+			continue
+		}
+		result := db.TestResult{
+			Name:              junitTestName(testCase),
+			Result:            db.SubmissionResultOK,
+			TimeInMillisecond: int64(testCase.Time * 1000),
+		}
+		switch {
+		case testCase.Failure != nil:
+			summary.Failed++
+			result.Result = db.SubmissionResultWrongAnswer
+			result.Message = truncateTestMessage(junitProblemMessage(testCase.Failure))
+			result.Expected, result.Actual = junitAssertionValues(testCase.Failure)
+		case testCase.Error != nil:
+			summary.Errors++
+			result.Result = db.SubmissionResultRuntimeError
+			if pythonCompileErrorTypes[testCase.Error.Type] {
+				result.Result = db.SubmissionResultCompileError
+			}
+			result.Message = truncateTestMessage(junitProblemMessage(testCase.Error))
+		default:
+			summary.Passed++
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// pythonCompileErrorTypes are the errors Python raises while importing a file
+// it can't parse. The unittest runner reports a test file whose import failed
+// as an errored test of that type.
+var pythonCompileErrorTypes = map[string]bool{
+	"SyntaxError":      true,
+	"IndentationError": true,
+	"TabError":         true,
+}
+
+func junitTestName(testCase junitTestCase) string {
+	if testCase.ClassName == "" {
+		return testCase.Name
+	}
+	return testCase.ClassName + "." + testCase.Name
+}
+
+func junitProblemMessage(problem *junitProblem) string {
+	if problem.Message != "" {
+		return problem.Message
+	}
+	if problem.Type != "" {
+		return problem.Type
+	}
+	return strings.TrimSpace(problem.Body)
+}
+
+// junitAssertionValues looks for the compared values in the failure's
+// message, then in its body, where reporters without a message attribute
+// put the whole assertion error.
+func junitAssertionValues(problem *junitProblem) (string, string) {
+	if expected, actual, ok := assertionValues(problem.Message); ok {
+		return expected, actual
+	}
+	expected, actual, _ := assertionValues(problem.Body)
+	return expected, actual
+}
+
+// assertionPattern finds the two values of a failed equality assertion in
+// its message. Most frameworks don't say which side is the expected one, so
+// each pattern assumes the order the framework's users write by convention,
+// e.g. assert actual == expected in pytest.
+type assertionPattern struct {
+	regex    *regexp.Regexp
+	expected int
+	actual   int
+}
+
+var assertionPatterns = []assertionPattern{
+	// JUnit 4 and 5: "expected: <3> but was: <4>".
+	{regexp.MustCompile(`(?s)expected:\s?<(.*)> but was:\s?<(.*)>`), 1, 2},
+	// pytest: "assert 4 == 3".
+	{regexp.MustCompile(`(?m)^(?:AssertionError: )?assert (.+) == (.+)$`), 2, 1},
+	// unittest's assertEqual: "4 != 3", with the custom message after " : ".
+	{regexp.MustCompile(`(?m)^(?:AssertionError: )?(.+?) != (.+?)(?: : .*)?$`), 2, 1},
+	// Node's assert.strictEqual: "4 !== 3".
+	{regexp.MustCompile(`(?m)^(.+) !== (.+)$`), 2, 1},
+	// Go's testing idiom: "Add(2, 2) = 4; want 3" or "got 4, want 3".
+	{regexp.MustCompile(`(?m)(?:got|=):? (.+?)[,;] want:? (.+)$`), 2, 1},
+	// Rust's assert_eq!: "  left: 4\n right: 3".
+	{regexp.MustCompile(`(?m)^\s*left: (.*)\n\s*right: (.*)$`), 2, 1},
+}
+
+func assertionValues(message string) (string, string, bool) {
+	for _, pattern := range assertionPatterns {
+		if match := pattern.regex.FindStringSubmatch(message); match != nil {
+			expected := truncateTestMessage(strings.TrimSpace(match[pattern.expected]))
+			actual := truncateTestMessage(strings.TrimSpace(match[pattern.actual]))
+			return expected, actual, true
+		}
+	}
+	return "", "", false
+}
+
+// withAssertionValues fills in the compared values of failed tests whose
+// harness only reports a message.
+func withAssertionValues(results []db.TestResult) []db.TestResult {
+	for i := range results {
+		if results[i].Result == db.SubmissionResultWrongAnswer {
+			results[i].Expected, results[i].Actual, _ = assertionValues(results[i].Message)
+		}
+	}
+	return results
+}
+
+// summarizeTestResults counts results for harnesses without a report of
+// their own; their skipped tests aren't reported at all.
+func summarizeTestResults(results []db.TestResult) *db.TestSummary {
+	if len(results) == 0 {
+		return nil
+	}
+	summary := &db.TestSummary{Total: len(results)}
+	for _, result := range results {
+		switch result.Result {
+		case db.SubmissionResultOK:
+			summary.Passed++
+		case db.SubmissionResultWrongAnswer:
+			summary.Failed++
+		default:
+			summary.Errors++
+		}
+	}
+	return summary
+}
+
+var (
+	unittestResultRegex = regexp.MustCompile(`(?m)^(\w+) \(([\w.]+)\)(?:\n.*)? \.\.\. (ok|FAIL|ERROR)\s*$`)
+	unittestDetailRegex = regexp.MustCompile(`(?ms)^(?:FAIL|ERROR): (\w+) \(([\w.]+)\)\n-+\n(.*?)(?:\n=+\n|\n-+\nRan )`)
+)
+
+// parseUnittestOutput reads the output of `python -m unittest -v`.
+func parseUnittestOutput(output string) []db.TestResult {
+	messages := make(map[string]string)
+	for _, match := range unittestDetailRegex.FindAllStringSubmatch(output, -1) {
+		messages[unittestTestName(match[1], match[2])] = lastNonEmptyLine(match[3])
+	}
+
+	var results []db.TestResult
+	for _, match := range unittestResultRegex.FindAllStringSubmatch(output, -1) {
+		name := unittestTestName(match[1], match[2])
+		result := db.TestResult{Name: name, Result: db.SubmissionResultOK}
+		switch match[3] {
+		case "FAIL":
+			result.Result = db.SubmissionResultWrongAnswer
+			result.Message = truncateTestMessage(messages[name])
+		case "ERROR":
+			result.Result = db.SubmissionResultRuntimeError
+			result.Message = truncateTestMessage(messages[name])
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+var (
+	goTestRunRegex    = regexp.MustCompile(`^=== (?:RUN|CONT)\s+(\S+)$`)
+	goTestResultRegex = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([\d.]+)s\)$`)
+	goTestLogRegex    = regexp.MustCompile(`^\s+\S+\.go:\d+: `)
+)
+
+// parseGoTestOutput reads the output of a test binary run with -test.v. A
+// panic fails the running test and stops the binary, so that test becomes a
+// runtime error, as does any test that started but never reported.
+func parseGoTestOutput(output string) []db.TestResult {
+	var (
+		results      []db.TestResult
+		current      string
+		started      []string
+		finished     = make(map[string]bool)
+		messages     = make(map[string]string)
+		panicMessage string
+		panicked     string
+	)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if match := goTestRunRegex.FindStringSubmatch(line); match != nil {
+			current = match[1]
+			started = append(started, current)
+			continue
+		}
+		if match := goTestResultRegex.FindStringSubmatch(line); match != nil {
+			name := match[2]
+			finished[name] = true
+			if match[1] == "SKIP" {
+				continue
+			}
+			seconds, _ := strconv.ParseFloat(match[3], 64)
+			result := db.TestResult{Name: name, Result: db.SubmissionResultOK, TimeInMillisecond: int64(seconds * 1000)}
+			if match[1] == "FAIL" {
+				result.Result = db.SubmissionResultWrongAnswer
+				result.Message = truncateTestMessage(messages[name])
+			}
+			results = append(results, result)
+			continue
+		}
+		if panicMessage == "" && strings.HasPrefix(line, "panic: ") {
+			panicMessage = truncateTestMessage(line)
+			panicked = current
+			continue
+		}
+		if current != "" && goTestLogRegex.MatchString(line) && messages[current] == "" {
+			messages[current] = strings.TrimSpace(line)
+		}
+	}
+	for i := range results {
+		if results[i].Name == panicked {
+			results[i].Result = db.SubmissionResultRuntimeError
+			results[i].Message = panicMessage
+		}
+	}
+	for _, name := range started {
+		if !finished[name] {
+			results = append(results, db.TestResult{Name: name, Result: db.SubmissionResultRuntimeError, Message: panicMessage})
+		}
+	}
+	return results
+}
+
+var (
+	rustTestResultRegex = regexp.MustCompile(`(?m)^test (\S+) \.\.\. (ok|FAILED|ignored)\s*$`)
+	rustTestDetailRegex = regexp.MustCompile(`(?ms)^---- (\S+) stdout ----\n(.*?)(?:^---- |^failures:$|\z)`)
+)
+
+// parseRustTestOutput reads the output of a libtest binary, the harness
+// behind cargo test.
+func parseRustTestOutput(output string) []db.TestResult {
+	messages := make(map[string]string)
+	for _, match := range rustTestDetailRegex.FindAllStringSubmatch(output, -1) {
+		messages[match[1]] = rustPanicMessage(match[2])
+	}
+
+	var results []db.TestResult
+	for _, match := range rustTestResultRegex.FindAllStringSubmatch(output, -1) {
+		if match[2] == "ignored" {
+			continue
+		}
+		result := db.TestResult{Name: match[1], Result: db.SubmissionResultOK}
+		if match[2] == "FAILED" {
+			result.Result = db.SubmissionResultWrongAnswer
+			result.Message = truncateTestMessage(messages[match[1]])
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// rustPanicMessage keeps what a failed test printed, minus the backtrace.
+func rustPanicMessage(detail string) string {
+	var lines []string
+	for _, line := range strings.Split(detail, "\n") {
+		if strings.HasPrefix(line, "stack backtrace:") {
+			break
+		}
+		if strings.HasPrefix(line, "note: ") && strings.Contains(line, "RUST_BACKTRACE") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// unittestTestName normalises the two formats Python prints: "(module.Class)"
+// before 3.11 and "(module.Class.method)" from 3.11 on.
+func unittestTestName(method string, qualifier string) string {
+	if strings.HasSuffix(qualifier, "."+method) {
+		return qualifier
+	}
+	return qualifier + "." + method
+}
+
+func lastNonEmptyLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func truncateTestMessage(message string) string {
+	if len(message) <= maxTestResultMessageLength {
+		return message
+	}
+	return message[:maxTestResultMessageLength] + "..."
+}
@@ -0,0 +1,101 @@
+package logic
+
+import (
+	"example/server/db"
+	"fmt"
+	"strings"
+)
+
+// isTestHidden reports whether the named test method is hidden. Setters may
+// name a method on its own, e.g. "add", or qualified the way the harness
+// reports it, e.g. "SolutionTest.add()".
+func isTestHidden(visibility db.TestVisibility, testName string) bool {
+	switch {
+	case matchesTestName(visibility.SampleTests, testName):
+		return false
+	case matchesTestName(visibility.HiddenTests, testName):
+		return true
+	default:
+		return visibility.IsHidden
+	}
+}
+
+func matchesTestName(names []string, testName string) bool {
+	testName = strings.TrimSuffix(testName, "()")
+	for _, name := range names {
+		name = strings.TrimSuffix(name, "()")
+		if name == testName || strings.HasSuffix(testName, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHiddenTests reports whether any test of the file may be hidden, in
+// which case its raw output can't be shown to contestants.
+func hasHiddenTests(visibility db.TestVisibility) bool {
+	return visibility.IsHidden || len(visibility.HiddenTests) > 0
+}
+
+func markHiddenTests(testResults []db.TestResult, visibility db.TestVisibility) {
+	for i := range testResults {
+		testResults[i].Hidden = isTestHidden(visibility, testResults[i].Name)
+	}
+}
+
+// redactHiddenTests leaves a submission as a contestant may see it: hidden
+// tests keep their verdict but lose their diagnostics, and a grading result
+// that may show them is rebuilt from the test results. The judge log holds
+// everything, so its ID goes as well.
+func redactHiddenTests(submission *db.Submission) {
+	submission.GradingResult, submission.TestResults = redactVerdict(
+		submission.Result, submission.GradingResult, submission.TestResults, submission.HidesTestDetails)
+	submission.LogFileID = ""
+	for i := range submission.VerdictHistory {
+		verdict := &submission.VerdictHistory[i]
+		verdict.GradingResult, verdict.TestResults = redactVerdict(
+			verdict.Result, verdict.GradingResult, verdict.TestResults, verdict.HidesTestDetails)
+		verdict.LogFileID = ""
+	}
+}
+
+func redactVerdict(result db.SubmissionResult, gradingResult string, testResults []db.TestResult, hidesTestDetails bool) (string, []db.TestResult) {
+	redacted := make([]db.TestResult, len(testResults))
+	for i, testResult := range testResults {
+		if testResult.Hidden {
+			testResult.Message = ""
+			testResult.Expected = ""
+			testResult.Actual = ""
+		}
+		redacted[i] = testResult
+	}
+	if !hidesTestDetails {
+		return gradingResult, redacted
+	}
+	return sampleGradingResult(result, redacted), redacted
+}
+
+// sampleGradingResult lists every test with its verdict, plus the
+// diagnostics of sample tests.
+func sampleGradingResult(result db.SubmissionResult, testResults []db.TestResult) string {
+	if len(testResults) == 0 {
+		return fmt.Sprintf("%s. The output isn't shown, since it may reveal hidden tests.", describeResult(result))
+	}
+	var builder strings.Builder
+	for i, testResult := range testResults {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		if testResult.Hidden {
+			fmt.Fprintf(&builder, "%s: %s (hidden)", testResult.Name, describeResult(testResult.Result))
+			continue
+		}
+		fmt.Fprintf(&builder, "%s: %s", testResult.Name, describeResult(testResult.Result))
+		if testResult.Expected != "" || testResult.Actual != "" {
+			fmt.Fprintf(&builder, "\n  expected: %s\n  actual:   %s", testResult.Expected, testResult.Actual)
+		} else if testResult.Message != "" {
+			fmt.Fprintf(&builder, "\n  %s", strings.ReplaceAll(testResult.Message, "\n", "\n  "))
+		}
+	}
+	return builder.String()
+}
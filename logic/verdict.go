@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"example/server/db"
+	"regexp"
+)
+
+// compileErrorRegex matches the errors Python raises while importing a file
+// it can't parse, and javac diagnostics of harnesses that compile at run time.
+var compileErrorRegex = regexp.MustCompile(`(?m)(\.java:\d+: error:|^\s*(SyntaxError|IndentationError|TabError):)`)
+
+// classifyVerdict turns the raw result of a test case run into a submission verdict.
+// Resource limits take priority over test results, since a killed run never
+// leaves a trustworthy report. Otherwise the structured test results decide,
+// as long as the harness exit code agrees with them. Without any, stderr is
+// only read to tell a source that didn't parse from a crash; what the
+// submission prints never makes a run pass.
+func classifyVerdict(output RunOutput) db.SubmissionResult {
+	if output.OutputLimitExceeded {
+		return db.SubmissionResultOutputLimitExceeded
+	}
+	if output.MemoryLimitExceeded {
+		return db.SubmissionResultMemoryLimitExceed
+	}
+	if output.TimeLimitExceeded || output.ExitCode == timeoutExitCode {
+		return db.SubmissionResultTimeLimitExceeded
+	}
+
+	if len(output.TestResults) > 0 {
+		result := worstTestResult(output.TestResults)
+		// A harness that failed without any failing test crashed or was
+		// killed after the reports it left, so they may miss tests.
+		if result == db.SubmissionResultOK && output.ExitCode != 0 {
+			return db.SubmissionResultRuntimeError
+		}
+		return result
+	}
+
+	if output.ExitCode != 0 {
+		if compileErrorRegex.MatchString(output.StdErr) {
+			return db.SubmissionResultCompileError
+		}
+		return db.SubmissionResultRuntimeError
+	}
+	// The harness exited cleanly without reporting a single test, e.g.
+	// because the submission exited before the tests ran.
+	if output.ExpectsTestResults {
+		return db.SubmissionResultRuntimeError
+	}
+	return db.SubmissionResultOK
+}
+
+// worstTestResult picks the verdict of the first failing test, preferring wrong
+// answers over runtime errors so a crash in one test doesn't hide failed assertions.
+func worstTestResult(testResults []db.TestResult) db.SubmissionResult {
+	result := db.SubmissionResultOK
+	for _, testResult := range testResults {
+		switch testResult.Result {
+		case db.SubmissionResultWrongAnswer:
+			return db.SubmissionResultWrongAnswer
+		case db.SubmissionResultOK:
+		default:
+			if result == db.SubmissionResultOK {
+				result = testResult.Result
+			}
+		}
+	}
+	return result
+}
@@ -9,6 +9,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	// WARNING!
@@ -27,11 +28,15 @@ import (
 )
 
 func main() {
+	// The native sandbox re-executes this binary to set up each run.
+	if logic.IsSandboxInit() {
+		logic.RunSandboxInit()
+	}
 	config, err := configs.NewConfig("")
 	if err != nil {
 		log.Fatal(err)
 	}
-	mongoClient, context, cancleFunc := db.SetupMongoDB()
+	mongoClient, mongoCtx, cancleFunc := db.SetupMongoDB()
 
 	logger := utils.InitLogger()
 	docker, err := utils.InitializeDockerClient()
@@ -39,7 +44,7 @@ func main() {
 		logger.Error(err.Error())
 	}
 
-	defer db.CloseConnection(mongoClient, context, cancleFunc)
+	defer db.CloseConnection(mongoClient, mongoCtx, cancleFunc)
 	testCaseDataCollection := mongoClient.Database(config.Database.Name).Collection(config.Database.MongoCollection.TestCase)
 	testCaseDataAccessor, err := db.NewTestCaseDataAccessor(testCaseDataCollection, logger)
 	if err != nil {
@@ -64,14 +69,52 @@ func main() {
 		logger.Error("fail to create account data accessor")
 	}
 
-	problemLogic := logic.NewProblemLogic(logger, problemDataAccessor, testCaseDataAccessor, submissionSnippetDataAccessor)
-	testCaseLogic := logic.NewTestCaseLogic(testCaseDataAccessor, problemDataAccessor, logger)
+	submissionJobDataCollection := mongoClient.Database(config.Database.Name).Collection(config.Database.MongoCollection.SubmissionJob)
+	submissionJobDataAccessor, err := db.NewSubmissionJobDataAccessor(submissionJobDataCollection, logger)
+	if err != nil {
+		logger.Error("fail to create submission job data accessor")
+	}
+
+	ioTestCaseDataCollection := mongoClient.Database(config.Database.Name).Collection(config.Database.MongoCollection.IOTestCase)
+	ioTestCaseDataAccessor, err := db.NewIOTestCaseDataAccessor(ioTestCaseDataCollection, logger)
+	if err != nil {
+		logger.Error("fail to create io test case data accessor")
+	}
+
+	problemFixtureDataCollection := mongoClient.Database(config.Database.Name).Collection(config.Database.MongoCollection.ProblemFixture)
+	problemFixtureDataAccessor, err := db.NewProblemFixtureDataAccessor(problemFixtureDataCollection, logger)
+	if err != nil {
+		logger.Error("fail to create problem fixture data accessor")
+	}
+
+	judgeInstanceDataCollection := mongoClient.Database(config.Database.Name).Collection(config.Database.MongoCollection.JudgeInstance)
+	judgeInstanceDataAccessor, err := db.NewJudgeInstanceDataAccessor(judgeInstanceDataCollection, logger)
+	if err != nil {
+		logger.Error("fail to create judge instance data accessor")
+	}
+
+	judgeLogDataAccessor, err := db.NewJudgeLogDataAccessor(mongoClient.Database(config.Database.Name), config.Database.MongoCollection.JudgeLog, logger)
+	if err != nil {
+		logger.Error("fail to create judge log data accessor")
+	}
+
 	judgeConfig := &config.Logic.Judge
-	judge, err := logic.NewJudgeLogic(logger, mongoClient, docker, judgeConfig, submissionDataAccessor, testCaseDataAccessor, problemDataAccessor)
+	problemLogic := logic.NewProblemLogic(logger, problemDataAccessor, testCaseDataAccessor, submissionSnippetDataAccessor, ioTestCaseDataAccessor, problemFixtureDataAccessor, judgeConfig)
+	testCaseLogic := logic.NewTestCaseLogic(testCaseDataAccessor, problemDataAccessor, logger)
+	judge, err := logic.NewJudgeLogic(logger, mongoClient, docker, judgeConfig, submissionDataAccessor, testCaseDataAccessor, problemDataAccessor, submissionJobDataAccessor, ioTestCaseDataAccessor, problemFixtureDataAccessor, judgeLogDataAccessor)
 	if err != nil {
-		logger.Error(err.Error())
+		logger.Fatal(err.Error())
+	}
+	janitor, err := logic.NewJanitorLogic(logger, docker, judgeConfig, judge, submissionDataAccessor, submissionJobDataAccessor, judgeInstanceDataAccessor)
+	if err != nil {
+		logger.Fatal(err.Error())
 	}
-	submissionLogic := logic.NewSubmissionLogic(judge, logger, mongoClient, submissionDataAccessor)
+	judgeCtx, stopJudge := context.WithCancel(context.Background())
+	defer stopJudge()
+	janitor.Reconcile(judgeCtx)
+	go janitor.Start(judgeCtx)
+	go judge.Start(judgeCtx)
+	submissionLogic := logic.NewSubmissionLogic(judge, logger, mongoClient, submissionDataAccessor, judgeLogDataAccessor)
 	submissionSnippetLogic := logic.NewSubmissionSnippetLogic(logger, submissionSnippetDataAccessor, problemDataAccessor)
 	testCaseAndSubmissionSnippetLogic := logic.NewTestCaseAndSubmissionSnippetLogic(logger, problemDataAccessor, testCaseDataAccessor, submissionSnippetDataAccessor)
 	tokenLogic, err := logic.NewTokenLogic(logger, accountDataAccessor, config.Token)
@@ -79,6 +122,11 @@ func main() {
 		logger.Error(err.Error())
 	}
 	accountLogic := logic.NewAccountLogic(logger, accountDataAccessor, tokenLogic)
+	ioTestCaseLogic := logic.NewIOTestCaseLogic(logger, ioTestCaseDataAccessor, problemDataAccessor)
+	problemFixtureLogic, err := logic.NewProblemFixtureLogic(logger, problemFixtureDataAccessor, problemDataAccessor, judgeConfig)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
 
 	server := handlers.NewAPIServerHandler(
 		submissionLogic,
@@ -89,6 +137,9 @@ func main() {
 		testCaseAndSubmissionSnippetLogic,
 		accountLogic,
 		tokenLogic,
+		ioTestCaseLogic,
+		problemFixtureLogic,
+		judge,
 		logger,
 	)
 	server.Start()